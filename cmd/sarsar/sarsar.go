@@ -5,14 +5,35 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 )
 
-var fInputFile string
+// inputFiles collects the repeated -f flags
+type inputFiles []string
+
+func (f *inputFiles) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *inputFiles) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+var fInputFiles inputFiles
 var fHelp bool
+var fStrict bool
+var fDiagnostics bool
+var fSections string
+var fDecimal string
 
 func init() {
-	flag.StringVar(&fInputFile, "f", "", "input file")
+	flag.Var(&fInputFiles, "f", "input file, glob or directory: sar or sadf output, or a sysstat 11.7.1+ saDD data file, compressed with gzip, xz or zstd or not; repeat to merge several days; - or a pipe for the standard input")
 	flag.BoolVar(&fHelp, "h", false, "print help message")
+	flag.BoolVar(&fStrict, "strict", false, "refuse input with unrecognized sections or malformed lines")
+	flag.StringVar(&fSections, "sections", "", "JSON file with additional or replacing section definitions")
+	flag.StringVar(&fDecimal, "decimal", "auto", "decimal mark of the values: auto (detected per file), point or comma")
+	flag.BoolVar(&fDiagnostics, "diagnostics", false, "print the skipped sections and lines of the input file and exit")
 }
 
 func main() {
@@ -24,14 +45,51 @@ func main() {
 		os.Exit(0)
 	}
 
-	if "" == fInputFile {
+	// a shell-expanded "-f sa*" leaves the other files as arguments
+	fInputFiles = append(fInputFiles, flag.Args()...)
+	if 0 == len(fInputFiles) && !stdinIsTerminal() {
+		fInputFiles = append(fInputFiles, sarsar.STDIN_INPUT)
+	}
+	if 0 == len(fInputFiles) {
 		fmt.Fprint(os.Stderr, "Error: input file required\n")
 		os.Exit(1)
 	}
 
-	if err := sarsar.SarSar(fInputFile); nil != err {
+	switch fDecimal {
+	case "auto":
+		sarsar.SetDecimalMark(sarsar.DECIMAL_AUTO)
+	case "point":
+		sarsar.SetDecimalMark(sarsar.DECIMAL_POINT)
+	case "comma":
+		sarsar.SetDecimalMark(sarsar.DECIMAL_COMMA)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown decimal mark \"%s\", use auto, point or comma\n", fDecimal)
+		os.Exit(1)
+	}
+
+	if "" != fSections {
+		if err := sarsar.LoadSectionConfig(fSections); nil != err {
+			fmt.Fprintf(os.Stderr, "Error: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	if fDiagnostics {
+		if err := sarsar.PrintDiagnostics(fInputFiles, os.Stdout); nil != err {
+			fmt.Fprintf(os.Stderr, "Error: %v", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if err := sarsar.SarSar(fInputFiles, fStrict); nil != err {
 		fmt.Fprintf(os.Stderr, "Error: %v", err)
 		os.Exit(1)
 	}
 }
 
+// stdinIsTerminal tells whether the standard input is typed rather than piped in
+func stdinIsTerminal() bool {
+	stat, err := os.Stdin.Stat()
+	return nil != err || 0 != stat.Mode()&os.ModeCharDevice
+}
@@ -0,0 +1,149 @@
+package sarsar
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// scaling modes of a Y axis
+const (
+	AXIS_AUTO    = iota // spans the values
+	AXIS_ZERO           // spans the values and zero
+	AXIS_PERCENT        // 0 to 100, the default of the columns named with a %
+	AXIS_LOG            // logarithmic, from the lowest positive value
+	AXIS_USER           // the range typed in
+	AXIS_END
+)
+
+// axisModeNames are short enough for the corner of the chart
+var axisModeNames = []string{"auto", "zero", "0-100", "log", "user"}
+
+// axisMode is how a column is scaled on its Y axis
+type axisMode struct {
+	mode int
+	min  float64 // the range of AXIS_USER
+	max  float64
+}
+
+func (m axisMode) String() string {
+	return axisModeNames[m.mode]
+}
+
+// axisModes remember the scaling of the columns, the instances of a section share theirs
+type axisModes map[chartKey]axisMode
+
+func modeKey(key chartKey) chartKey {
+	key.instance = NO_INSTANCE
+	return key
+}
+
+// get returns the mode picked for the column, 0-100 for the % columns none was picked for
+func (m axisModes) get(key chartKey) axisMode {
+	mode, found := m[modeKey(key)]
+	if !found && strings.Contains(key.col, "%") {
+		mode.mode = AXIS_PERCENT
+	}
+	return mode
+}
+
+// next switches the column to the next mode that applies to it: 0-100 to the % columns,
+// user to those given a range
+func (m axisModes) next(key chartKey) axisMode {
+	mode := m.get(key)
+	for {
+		mode.mode = (mode.mode + 1) % AXIS_END
+		if AXIS_PERCENT == mode.mode && !strings.Contains(key.col, "%") {
+			continue
+		}
+		if AXIS_USER == mode.mode && mode.min >= mode.max {
+			continue
+		}
+		break
+	}
+	m[modeKey(key)] = mode
+	return mode
+}
+
+func (m axisModes) setRange(key chartKey, min, max float64) error {
+	if !(min < max) || math.IsInf(min, 0) || math.IsInf(max, 0) {
+		return fmt.Errorf("the Y range needs min < max, got %v %v", min, max)
+	}
+	m[modeKey(key)] = axisMode{mode: AXIS_USER, min: min, max: max}
+	return nil
+}
+
+// parseAxisRange reads the "min max" typed in for AXIS_USER
+func parseAxisRange(text string) (float64, float64, error) {
+	fields := strings.Fields(text)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("expected min and max, got %q", text)
+	}
+	min, err := strconv.ParseFloat(fields[0], 64)
+	if nil != err {
+		return 0, 0, fmt.Errorf("invalid min %q", fields[0])
+	}
+	max, err := strconv.ParseFloat(fields[1], 64)
+	if nil != err {
+		return 0, 0, fmt.Errorf("invalid max %q", fields[1])
+	}
+	return min, max, nil
+}
+
+func formatAxisRange(min, max float64) string {
+	return fmt.Sprintf("%s %s", strconv.FormatFloat(min, 'g', -1, 64), strconv.FormatFloat(max, 'g', -1, 64))
+}
+
+// position maps a value to the height of the axis, 0 at the bottom and 1 at the top
+func (r chartRange) position(val float64) float64 {
+	if r.log {
+		if val <= 0 {
+			return 0
+		}
+		return (math.Log10(val) - math.Log10(r.bottom)) / (math.Log10(r.top) - math.Log10(r.bottom))
+	}
+	return (val - r.bottom) / (r.top - r.bottom)
+}
+
+// valueAt is the value at a position of the axis
+func (r chartRange) valueAt(pos float64) float64 {
+	if r.log {
+		return math.Pow(10, math.Log10(r.bottom)+pos*(math.Log10(r.top)-math.Log10(r.bottom)))
+	}
+	return r.bottom + pos*(r.top-r.bottom)
+}
+
+// scaleChartRange applies a mode to the span of the values
+func scaleChartRange(r chartRange, values [][]float64, mode axisMode) chartRange {
+	switch mode.mode {
+	case AXIS_ZERO:
+		r.bottom, r.top = math.Min(r.bottom, 0), math.Max(r.top, 0)
+	case AXIS_PERCENT:
+		// fixed, the values over 100 (%util of a device for one) are drawn on the top edge
+		r.bottom, r.top = 0, 100
+	case AXIS_USER:
+		r.bottom, r.top = mode.min, mode.max
+	case AXIS_LOG:
+		positive := math.Inf(1)
+		for _, vals := range values {
+			for _, val := range vals {
+				if val > 0 && val < positive {
+					positive = val
+				}
+			}
+		}
+		// nothing to take the log of
+		if math.IsInf(positive, 1) {
+			break
+		}
+		r.bottom, r.log = positive, true
+		if r.bottom == r.top {
+			r.bottom, r.top = r.bottom/10, r.top*10
+		}
+	}
+	if r.bottom == r.top {
+		r.bottom, r.top = r.bottom-1, r.top+1
+	}
+	return r
+}
@@ -0,0 +1,108 @@
+package sarsar
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"github.com/gizak/termui"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAxisModesNext(t *testing.T) {
+	modes := axisModes{}
+	idle := chartKey{section: "CPU", instance: "all", col: "%idle"}
+	rx := chartKey{section: "DEV", instance: "eth0", col: "rxkB/s"}
+
+	// the % columns start at 0-100
+	assert.Equal(t, AXIS_PERCENT, modes.get(idle).mode)
+	assert.Equal(t, AXIS_AUTO, modes.get(rx).mode)
+	var cycled []string
+	for idx := 0; idx < 4; idx++ {
+		cycled = append(cycled, modes.next(idle).String())
+	}
+	assert.Equal(t, []string{"log", "auto", "zero", "0-100"}, cycled)
+
+	// no 0-100 for the other columns, user once given a range
+	cycled = nil
+	for idx := 0; idx < 3; idx++ {
+		cycled = append(cycled, modes.next(rx).String())
+	}
+	assert.Equal(t, []string{"zero", "log", "auto"}, cycled)
+	assert.NoError(t, modes.setRange(rx, 0, 1000))
+	assert.Equal(t, "user", modes.get(rx).String())
+	cycled = nil
+	for idx := 0; idx < 4; idx++ {
+		cycled = append(cycled, modes.next(rx).String())
+	}
+	assert.Equal(t, []string{"auto", "zero", "log", "user"}, cycled)
+
+	// remembered per column, whatever the instance
+	modes.next(idle)
+	assert.Equal(t, AXIS_LOG, modes.get(chartKey{section: "CPU", instance: "0", col: "%idle"}).mode)
+
+	assert.Error(t, modes.setRange(rx, 5, 5))
+	assert.Error(t, modes.setRange(rx, math.Inf(-1), 5))
+}
+
+func TestParseAxisRange(t *testing.T) {
+	min, max, err := parseAxisRange(" 90  100.5 ")
+	assert.NoError(t, err)
+	assert.Equal(t, 90.0, min)
+	assert.Equal(t, 100.5, max)
+	assert.Equal(t, "90 100.5", formatAxisRange(min, max))
+
+	_, _, err = parseAxisRange("90")
+	assert.Error(t, err)
+	_, _, err = parseAxisRange("a 100")
+	assert.Error(t, err)
+}
+
+func TestMakeChartRangeModes(t *testing.T) {
+	series := []chartSeries{{values: []float64{97, 99, math.NaN()}}}
+	rangeOf := func(mode axisMode) chartRange {
+		series[0].axis = mode
+		r, found := makeChartRange(series, false)
+		assert.True(t, found)
+		return r
+	}
+	assert.Equal(t, chartRange{bottom: 97, top: 99}, rangeOf(axisMode{}))
+	assert.Equal(t, chartRange{bottom: 0, top: 99}, rangeOf(axisMode{mode: AXIS_ZERO}))
+	assert.Equal(t, chartRange{bottom: 0, top: 100}, rangeOf(axisMode{mode: AXIS_PERCENT}))
+	series[0].values = []float64{97, 150}
+	assert.Equal(t, chartRange{bottom: 0, top: 100}, rangeOf(axisMode{mode: AXIS_PERCENT}))
+	series[0].values = []float64{97, 99, math.NaN()}
+	assert.Equal(t, chartRange{bottom: 90, top: 95}, rangeOf(axisMode{mode: AXIS_USER, min: 90, max: 95}))
+
+	series[0].values = []float64{0, 10, 1000}
+	r := rangeOf(axisMode{mode: AXIS_LOG})
+	assert.Equal(t, chartRange{bottom: 10, top: 1000, log: true}, r)
+	assert.Equal(t, 0.0, r.position(0))
+	assert.InDelta(t, 0.5, r.position(100), 1e-9)
+	assert.InDelta(t, 100, r.valueAt(0.5), 1e-9)
+
+	// nothing positive to take the log of
+	series[0].values = []float64{-1, 0}
+	assert.Equal(t, chartRange{bottom: -1, top: 0}, rangeOf(axisMode{mode: AXIS_LOG}))
+
+	// the right axis has its own mode
+	series = []chartSeries{
+		{values: []float64{50}},
+		{values: []float64{50}, right: true, axis: axisMode{mode: AXIS_ZERO}},
+	}
+	r, _ = makeChartRange(series, false)
+	assert.Equal(t, chartRange{bottom: 49, top: 51}, r)
+	r, _ = makeChartRange(series, true)
+	assert.Equal(t, chartRange{bottom: 0, top: 50}, r)
+}
+
+func TestMakeChartPointsAxisMode(t *testing.T) {
+	labels := []string{"12:00", "12:01", "12:02", "12:03"}
+	series := []chartSeries{{values: []float64{97, 98, 99, 98}, color: termui.ColorGreen, axis: axisMode{mode: AXIS_PERCENT}}}
+	points, _ := makeChartPoints(40, 8, labels, series)
+	assert.True(t, strings.HasPrefix(cellsText(points[5]), "0.00"))
+	assert.True(t, strings.HasPrefix(cellsText(points[7]), "0-100"))
+	// the values are near the top, nothing is drawn in the lower rows
+	for _, cell := range points[5] {
+		assert.NotEqual(t, termui.ColorGreen, cell.Fg)
+	}
+}
@@ -5,13 +5,58 @@ import (
 	"fmt"
 	"github.com/gizak/termui"
 	"github.com/miguelmota/cointop/pkg/color"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 const (
 	CHART_HEIGHT = 10
+
+	BREAK_LINE_MARKER    = '╎'
+	BREAK_GAP_MARKER     = '┴'
+	BREAK_RESTART_MARKER = 'R'
+	BREAK_MISSING_MARKER = '?'
+
+	LEGEND_MARKER = '■'
+
+	CURSOR_LINE_MARKER = '│'
+	CURSOR_AXIS_MARKER = '▲'
+	AXIS_FG       = termui.ColorWhite
 )
 
-func renderChartView(g *gocui.Gui, labels []string, values []float64) error {
+// CHART_COLORS tell the overlaid series apart, in marking order
+var CHART_COLORS = []termui.Attribute{termui.ColorGreen, termui.ColorYellow, termui.ColorCyan, termui.ColorRed, termui.ColorBlue}
+
+var chartColorFuncs = map[termui.Attribute]func(a ...interface{}) string{
+	termui.ColorWhite:  color.White,
+	termui.ColorGreen:  color.Green,
+	termui.ColorYellow: color.Yellow,
+	termui.ColorCyan:   color.Cyan,
+	termui.ColorRed:    color.Red,
+	termui.ColorBlue:   color.Blue,
+}
+
+// chartSeries is one line of the chart
+type chartSeries struct {
+	name     string
+	values   []float64 // NaN for the missing values
+	decimals int       // to print the values as sar did
+	color    termui.Attribute
+	right    bool     // scaled on the right Y axis
+	axis     axisMode // the first series of an axis scales it
+}
+
+// chartRange is the span of values a Y axis shows
+type chartRange struct {
+	bottom float64
+	top    float64
+	log    bool // bottom is then positive
+}
+
+// renderChartView draws the series, with a crosshair at the sample cursor unless it's negative
+func renderChartView(g *gocui.Gui, labels []string, series []chartSeries, breaks []sarBreak, cursor int) error {
 	maxX, _ := g.Size()
 
 	g.DeleteView("chart")
@@ -21,66 +66,408 @@ func renderChartView(g *gocui.Gui, labels []string, values []float64) error {
 		}
 		v.Frame = false
 
-		makeChartView(g, v, maxX-2, CHART_HEIGHT, labels, values)
+		width, height := v.Size()
+		makeChartView(g, v, width, height, labels, series, breaks, cursor)
 	}
 	return nil
 }
 
-func makeChartView(g *gocui.Gui, view *gocui.View, maxX int, height int, labels []string, values []float64) {
-	chartPoints := makeChartPoints(maxX, height, labels, values)
-
+func makeChartView(g *gocui.Gui, view *gocui.View, maxX int, height int, labels []string, series []chartSeries, breaks []sarBreak, cursor int) {
 	var body string
+	if cursor >= 0 && cursor < len(labels) {
+		body = makeReadout(labels[cursor], series, cursor) + "\n"
+		height--
+	} else if len(series) > 1 {
+		body = makeLegend(series) + "\n"
+		height--
+	}
+
+	filled := make([]chartSeries, len(series))
+	for idx := range series {
+		filled[idx] = series[idx]
+		filled[idx].values, breaks = fillMissing(series[idx].values, breaks)
+	}
+	chartPoints, bucket := makeChartPoints(maxX, height, labels, filled)
+	markChartBreaks(chartPoints, breaks, bucket)
+	markChartCursor(chartPoints, cursor, bucket)
+
 	for i := range chartPoints {
-		var s string
-		for j := range chartPoints[i] {
-			p := chartPoints[i][j]
-			s = fmt.Sprintf("%s%c", s, p.Ch)
-		}
-		body = fmt.Sprintf("%s%s\n", body, s)
+		body = fmt.Sprintf("%s%s\n", body, colorizeCells(chartPoints[i]))
 	}
 
 	g.Update(func(gui *gocui.Gui) error {
-		fmt.Fprint(view, color.White(body))
+		fmt.Fprint(view, body)
 		return nil
 	})
 }
 
-func makeChartPoints(maxX int, height int, labels []string, values []float64) [][]termui.Cell {
-	chart := termui.NewLineChart()
-	chart.Height = height
-	chart.Width = maxX
-	chart.AxesColor = termui.ColorWhite
-	chart.LineColor = termui.ColorGreen
-	chart.Border = false
-	chart.Mode = "braille"
-	chart.Data = values
-	chart.DataLabels = labels
-
-	termui.Body = termui.NewGrid()
-	termui.Body.Width = maxX
-	termui.Body.AddRows(
-		termui.NewRow(
-			termui.NewCol(12, 0, chart),
-		),
-	)
-
-	var points [][]termui.Cell
-	{
-		// calculate layout
-		termui.Body.Align()
-		w := termui.Body.Width
-		h := height
-		row := termui.Body.Rows[0]
-		b := row.Buffer()
-		for i := 0; i < h; i = i + 1 {
-			var rowPoints []termui.Cell
-			for j := 0; j < w; j = j + 1 {
-				p := b.At(j, i)
-				rowPoints = append(rowPoints, p)
+// makeLegend names the series in their colors, those of the right Y axis are marked so
+func makeLegend(series []chartSeries) string {
+	var parts []string
+	for _, s := range series {
+		part := fmt.Sprintf("%s %s", colorize(string(LEGEND_MARKER), s.color), s.name)
+		if s.right {
+			part += " (right)"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "  ")
+}
+
+// makeReadout tells the values of the series at the sample under the crosshair
+func makeReadout(label string, series []chartSeries, cursor int) string {
+	parts := []string{label}
+	for _, s := range series {
+		val := "-"
+		if !math.IsNaN(s.values[cursor]) {
+			val = strconv.FormatFloat(s.values[cursor], 'f', s.decimals, 64)
+		}
+		parts = append(parts, fmt.Sprintf("%s %s %s", colorize(string(LEGEND_MARKER), s.color), s.name, val))
+	}
+	return strings.Join(parts, "  ")
+}
+
+func colorize(text string, fg termui.Attribute) string {
+	if fn, found := chartColorFuncs[fg]; found {
+		return fn(text)
+	}
+	return text
+}
+
+// colorizeCells prints a row of the chart, one escape sequence per run of a color
+func colorizeCells(cells []termui.Cell) string {
+	var buf strings.Builder
+	for start := 0; start < len(cells); {
+		end := start
+		var run []rune
+		for ; end < len(cells) && cells[end].Fg == cells[start].Fg; end++ {
+			ch := cells[end].Ch
+			if 0 == ch {
+				ch = ' '
 			}
-			points = append(points, rowPoints)
+			run = append(run, ch)
 		}
+		buf.WriteString(colorize(string(run), cells[start].Fg))
+		start = end
 	}
+	return buf.String()
+}
 
-	return points
+// makeChartRange spans the values of the series of one Y axis, scaled by the mode of its first series,
+// false if the axis has none
+func makeChartRange(series []chartSeries, right bool) (chartRange, bool) {
+	r := chartRange{bottom: math.Inf(1), top: math.Inf(-1)}
+	var mode *axisMode
+	var values [][]float64
+	for idx, s := range series {
+		if s.right != right {
+			continue
+		}
+		if nil == mode {
+			mode = &series[idx].axis
+		}
+		values = append(values, s.values)
+		for _, val := range s.values {
+			if math.IsNaN(val) || math.IsInf(val, 0) {
+				continue
+			}
+			r.bottom = math.Min(r.bottom, val)
+			r.top = math.Max(r.top, val)
+		}
+	}
+	if math.IsInf(r.bottom, 1) {
+		return r, false
+	}
+	return scaleChartRange(r, values, *mode), true
+}
+
+// formatAxisValue shortens a value for the Y axis labels
+func formatAxisValue(val float64) string {
+	s := fmt.Sprintf("%.2f", val)
+	if len(s) > 7 {
+		s = fmt.Sprintf("%.2e", val)
+	}
+	return s
+}
+
+// braille cells hold 2x4 dots, the bits of each dot by column and row from the top
+var brailleDots = [2][4]rune{{0x01, 0x02, 0x04, 0x40}, {0x08, 0x10, 0x20, 0x80}}
+
+const BRAILLE_BLANK = 0x2800
+
+// chartBucket is how many samples share a column of braille dots, so that all fit in the cells
+func chartBucket(samples, cells int) int {
+	if cells <= 0 || samples <= 2*cells {
+		return 1
+	}
+	return (samples + 2*cells - 1) / (2 * cells)
+}
+
+// chartColumn returns the cell of a sample, right of the Y axis at originX
+func chartColumn(originX, idx, bucket int) int {
+	return originX + 1 + idx/bucket/2
+}
+
+// makeChartPoints plots the series in braille right of the Y axis, with the X labels under the axis.
+// Series of the right Y axis get their own scale. A column of dots holds a bucket of samples, drawn
+// from their lowest to their highest value so that spikes show however many samples there are;
+// the bucket size is returned and printed right of the X labels.
+func makeChartPoints(maxX int, height int, labels []string, series []chartSeries) ([][]termui.Cell, int) {
+	points := make([][]termui.Cell, height)
+	for y := range points {
+		points[y] = make([]termui.Cell, maxX)
+		for x := range points[y] {
+			points[y][x] = termui.Cell{Ch: ' ', Fg: AXIS_FG}
+		}
+	}
+	if height < 3 || maxX < 3 || 0 == len(series) {
+		return points, 1
+	}
+
+	put := func(x, y int, text string) {
+		for _, ch := range text {
+			if x >= 0 && x < maxX {
+				points[y][x].Ch = ch
+			}
+			x++
+		}
+	}
+
+	axisY := height - 2
+	dots := axisY * 4
+	ranges := map[bool]chartRange{}
+	labelsY := map[bool][]string{}
+	labelWidth := map[bool]int{}
+	for _, right := range []bool{false, true} {
+		r, found := makeChartRange(series, right)
+		if !found {
+			continue
+		}
+		ranges[right] = r
+		for y := axisY - 1; y >= 0; y -= 2 {
+			label := formatAxisValue(r.valueAt(float64((axisY-1-y)*4) / float64(dots-1)))
+			labelsY[right] = append(labelsY[right], label)
+			if len(label) > labelWidth[right] {
+				labelWidth[right] = len(label)
+			}
+		}
+	}
+
+	originX := labelWidth[false]
+	endX := maxX
+	if _, found := ranges[true]; found {
+		endX = maxX - labelWidth[true] - 1
+	}
+	if endX <= originX+1 {
+		return points, 1
+	}
+	bucket := chartBucket(len(labels), endX-originX-1)
+
+	// axes and their labels
+	points[axisY][originX].Ch = termui.ORIGIN
+	for x := originX + 1; x < endX; x++ {
+		points[axisY][x].Ch = termui.HDASH
+	}
+	for y := 0; y < axisY; y++ {
+		points[y][originX].Ch = termui.VDASH
+		if endX < maxX {
+			points[y][endX].Ch = termui.VDASH
+		}
+	}
+	for idx, label := range labelsY[false] {
+		put(0, axisY-1-2*idx, label)
+	}
+	for idx, label := range labelsY[true] {
+		put(endX+1, axisY-1-2*idx, label)
+	}
+	// the scaling mode under the labels of its axis unless auto, the X labels start after it
+	labelsX := originX
+	scaled := map[bool]bool{}
+	for _, s := range series {
+		if scaled[s.right] {
+			continue
+		}
+		scaled[s.right] = true
+		if AXIS_AUTO == s.axis.mode {
+			continue
+		}
+		mode := s.axis.String()
+		if s.right {
+			put(endX+1, height-1, mode)
+		} else {
+			put(0, height-1, mode)
+			if len(mode) >= labelsX {
+				labelsX = len(mode) + 1
+			}
+		}
+	}
+	labelsEndX := endX
+	if bucket > 1 {
+		indicator := fmt.Sprintf("%d samples/dot", bucket)
+		labelsEndX = endX - len(indicator) - 2
+		put(endX-len(indicator), height-1, indicator)
+	}
+	// each label starts at the column of its sample, after the scaling mode
+	idx := 0
+	if labelsX > originX+1 {
+		idx = labelsX - originX - 1
+	}
+	for ; 2*idx*bucket < len(labels); idx += len(labels[2*idx*bucket]) + 2 {
+		x := chartColumn(originX, 2*idx*bucket, bucket)
+		if x+len(labels[2*idx*bucket]) > labelsEndX {
+			break
+		}
+		put(x, height-1, labels[2*idx*bucket])
+	}
+
+	// samples
+	bits := make([][]rune, axisY)
+	for y := range bits {
+		bits[y] = make([]rune, maxX)
+	}
+	toDot := func(val float64, r chartRange) int {
+		dot := int(math.Round(r.position(val) * float64(dots-1)))
+		if dot < 0 {
+			return 0
+		} else if dot >= dots {
+			return dots - 1
+		}
+		return dot
+	}
+	for _, s := range series {
+		r := ranges[s.right]
+		for column := 0; column*bucket < len(s.values); column++ {
+			x := chartColumn(originX, column*bucket, bucket)
+			if x >= endX {
+				break
+			}
+			end := (column + 1) * bucket
+			if end > len(s.values) {
+				end = len(s.values)
+			}
+			low, high := math.Inf(1), math.Inf(-1)
+			for _, val := range s.values[column*bucket : end] {
+				if !math.IsNaN(val) {
+					low, high = math.Min(low, val), math.Max(high, val)
+				}
+			}
+			if math.IsInf(low, 1) {
+				continue
+			}
+			for dot := toDot(low, r); dot <= toDot(high, r); dot++ {
+				y := axisY - 1 - dot/4
+				bits[y][x] |= brailleDots[column%2][3-dot%4]
+				points[y][x] = termui.Cell{Ch: BRAILLE_BLANK | bits[y][x], Fg: s.color}
+			}
+		}
+	}
+	return points, bucket
+}
+
+// fillMissing replaces missing (NaN) values by the previous value so that the chart stays drawable,
+// and marks each of them as a break unless there is one already
+func fillMissing(values []float64, breaks []sarBreak) ([]float64, []sarBreak) {
+	filled := make([]float64, len(values))
+	last := float64(0)
+	for _, val := range values {
+		if !math.IsNaN(val) {
+			last = val
+			break
+		}
+	}
+	marked := map[int]bool{}
+	for _, b := range breaks {
+		marked[b.index] = true
+	}
+	var missing []sarBreak
+	for idx, val := range values {
+		if math.IsNaN(val) {
+			if !marked[idx] {
+				missing = append(missing, sarBreak{index: idx, missing: true})
+			}
+			val = last
+		}
+		filled[idx] = val
+		last = val
+	}
+	if len(missing) == 0 {
+		return filled, breaks
+	}
+
+	merged := append(append([]sarBreak{}, breaks...), missing...)
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].index < merged[j].index
+	})
+	return filled, merged
+}
+
+// markChartBreaks draws a dashed line at every break of the series, with the kind of
+// break marked on the x-axis
+func markChartBreaks(points [][]termui.Cell, breaks []sarBreak, bucket int) {
+	if len(points) < 2 {
+		return
+	}
+
+	// two columns of dots per cell, a bucket of samples each, right of the y-axis origin
+	axisY := len(points) - 2
+	originX := -1
+	for x, p := range points[axisY] {
+		if termui.ORIGIN == p.Ch {
+			originX = x
+			break
+		}
+	}
+	if originX < 0 {
+		return
+	}
+
+	for _, b := range breaks {
+		x := chartColumn(originX, b.index, bucket)
+		if x >= len(points[axisY]) {
+			break
+		}
+		for y := 0; y < axisY; y++ {
+			if ' ' == points[y][x].Ch || 0 == points[y][x].Ch {
+				points[y][x].Ch = BREAK_LINE_MARKER
+			}
+		}
+		if b.restart {
+			points[axisY][x].Ch = BREAK_RESTART_MARKER
+		} else if b.missing {
+			points[axisY][x].Ch = BREAK_MISSING_MARKER
+		} else {
+			points[axisY][x].Ch = BREAK_GAP_MARKER
+		}
+	}
+}
+
+// markChartCursor draws the crosshair through the cell of the sample cursor, leaving the plotted dots
+func markChartCursor(points [][]termui.Cell, cursor, bucket int) {
+	if len(points) < 2 || cursor < 0 {
+		return
+	}
+
+	axisY := len(points) - 2
+	for x, p := range points[axisY] {
+		if termui.ORIGIN != p.Ch {
+			continue
+		}
+		x = chartColumn(x, cursor, bucket)
+		if x >= len(points[axisY]) {
+			return
+		}
+		// past the samples, under the right axis labels
+		switch points[axisY][x].Ch {
+		case termui.HDASH, BREAK_GAP_MARKER, BREAK_RESTART_MARKER, BREAK_MISSING_MARKER:
+		default:
+			return
+		}
+		for y := 0; y < axisY; y++ {
+			if ' ' == points[y][x].Ch || 0 == points[y][x].Ch || BREAK_LINE_MARKER == points[y][x].Ch {
+				points[y][x].Ch = CURSOR_LINE_MARKER
+			}
+		}
+		points[axisY][x].Ch = CURSOR_AXIS_MARKER
+		return
+	}
 }
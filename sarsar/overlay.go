@@ -0,0 +1,173 @@
+package sarsar
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// chartKey names a charted column
+type chartKey struct {
+	section  string
+	instance string
+	col      string
+}
+
+// makeChartKey reads a column from the keys of a menu leaf, which come leaf first
+func makeChartKey(keys []string) (chartKey, error) {
+	switch len(keys) {
+	case 3:
+		return chartKey{section: keys[1], instance: NO_INSTANCE, col: keys[0]}, nil
+	case 4:
+		return chartKey{section: keys[2], instance: keys[1], col: keys[0]}, nil
+	}
+	return chartKey{}, fmt.Errorf("unexpected menu key depth: %+v", keys)
+}
+
+// String names the column in the legend, the section is left out as the menu shows it
+func (k chartKey) String() string {
+	if NO_INSTANCE == k.instance {
+		return k.col
+	}
+	return fmt.Sprintf("%s %s", k.instance, k.col)
+}
+
+// chartData is what the chart shows: its series aligned on the union of their sample times
+type chartData struct {
+	keys   []chartKey
+	times  []time.Time
+	labels []string
+	series []chartSeries
+	breaks []sarBreak
+}
+
+// makeChartData lines up columns for the chart, a series lacking a sample of another one gets
+// a missing value there. With dualAxis the first column is scaled on the left Y axis and the
+// others on the right one.
+func (s *sarFile) makeChartData(keys []chartKey, dualAxis bool) (*chartData, error) {
+	var all []*sarSeries
+	seen := map[time.Time]bool{}
+	data := &chartData{keys: keys}
+	for _, key := range keys {
+		series, err := s.getSeries(key.section, key.instance)
+		if nil != err {
+			return nil, err
+		}
+		all = append(all, series)
+		for _, ts := range series.times {
+			if !seen[ts] {
+				seen[ts] = true
+				data.times = append(data.times, ts)
+			}
+		}
+	}
+	sort.Slice(data.times, func(i, j int) bool {
+		return data.times[i].Before(data.times[j])
+	})
+	rows := make(map[time.Time]int, len(data.times))
+	data.labels = make([]string, len(data.times))
+	for row, ts := range data.times {
+		rows[ts] = row
+		data.labels[row] = ts.Format("Jan 02 15:04:05")
+	}
+
+	for idx, key := range keys {
+		values := make([]float64, len(data.times))
+		for row := range values {
+			values[row] = math.NaN()
+		}
+		series := all[idx]
+		decimals := 0
+		if col, found := series.schema.index[key.col]; found {
+			decimals = series.schema.columns[col].decimals
+			for row, ts := range series.times {
+				if val, found := series.value(col, row); found {
+					values[rows[ts]] = val
+				}
+			}
+		}
+		data.series = append(data.series, chartSeries{
+			name:     key.String(),
+			values:   values,
+			decimals: decimals,
+			color:    CHART_COLORS[idx%len(CHART_COLORS)],
+			right:    dualAxis && idx > 0,
+		})
+	}
+	data.breaks = alignBreaks(all, rows)
+	return data, nil
+}
+
+// alignBreaks maps the breaks of the series onto their union of sample times, each one just after the
+// last sample before it. A restart wins over a gap at the same sample.
+func alignBreaks(all []*sarSeries, rows map[time.Time]int) []sarBreak {
+	found := map[int]int{}
+	var breaks []sarBreak
+	for _, series := range all {
+		for _, b := range series.breaks {
+			b.index = rows[series.times[b.index-1]] + 1
+			if idx, dup := found[b.index]; dup {
+				breaks[idx].restart = breaks[idx].restart || b.restart
+				continue
+			}
+			found[b.index] = len(breaks)
+			breaks = append(breaks, b)
+		}
+	}
+	sort.Slice(breaks, func(i, j int) bool {
+		return breaks[i].index < breaks[j].index
+	})
+	return breaks
+}
+
+// slice keeps the samples [lo, hi) of the chart
+func (d *chartData) slice(lo, hi int) *chartData {
+	sliced := &chartData{
+		keys:   d.keys,
+		times:  d.times[lo:hi],
+		labels: d.labels[lo:hi],
+	}
+	for _, series := range d.series {
+		series.values = series.values[lo:hi]
+		sliced.series = append(sliced.series, series)
+	}
+	// a break at lo is before the first visible sample
+	for _, b := range d.breaks {
+		if b.index > lo && b.index < hi {
+			b.index -= lo
+			sliced.breaks = append(sliced.breaks, b)
+		}
+	}
+	return sliced
+}
+
+// timeIndex returns the sample at ts, or the first one after it
+func (d *chartData) timeIndex(ts time.Time) int {
+	idx := sort.Search(len(d.times), func(i int) bool {
+		return !d.times[i].Before(ts)
+	})
+	if idx == len(d.times) {
+		return len(d.times) - 1
+	}
+	return idx
+}
+
+// extreme returns the sample of the highest (max) or lowest value of the first series,
+// which is the one of the left Y axis, -1 if it has no values
+func (d *chartData) extreme(max bool) int {
+	found := -1
+	if len(d.series) == 0 {
+		return found
+	}
+	values := d.series[0].values
+	for idx, val := range values {
+		if math.IsNaN(val) {
+			continue
+		}
+		if found < 0 || max && val > values[found] || !max && val < values[found] {
+			found = idx
+		}
+	}
+	return found
+}
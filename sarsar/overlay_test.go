@@ -0,0 +1,233 @@
+package sarsar
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"github.com/gizak/termui"
+	"github.com/stretchr/testify/assert"
+)
+
+// the queue length section misses the 12:10:01 sample of the CPU section
+const sarOverlay = `Linux 4.15.0-20-generic (db01) 	2018-03-14 	_x86_64_	(2 CPU)
+
+12:00:01        CPU     %usr    %nice     %sys  %iowait    %steal      %irq     %soft    %guest    %gnice     %idle
+12:10:01        all      1.50      0.00      0.50      3.00      0.00      0.00      0.00      0.00      0.00     95.00
+12:20:01        all      2.50      0.00      0.50      1.00      0.00      0.00      0.00      0.00      0.00     96.00
+
+12:00:01      runq-sz  plist-sz   ldavg-1   ldavg-5  ldavg-15   blocked
+12:20:01            2       310      0.60      0.45      0.32         1
+12:30:01            3       320      0.70      0.50      0.35         0
+`
+
+func TestMakeChartKey(t *testing.T) {
+	key, err := makeChartKey([]string{"%usr", "all", "CPU util", "root"})
+	assert.NoError(t, err)
+	assert.Equal(t, chartKey{section: "CPU util", instance: "all", col: "%usr"}, key)
+	assert.Equal(t, "all %usr", key.String())
+
+	key, err = makeChartKey([]string{"runq-sz", "queue", "root"})
+	assert.NoError(t, err)
+	assert.Equal(t, "runq-sz", key.String())
+
+	_, err = makeChartKey([]string{"root"})
+	assert.Error(t, err)
+}
+
+func TestMakeChartData(t *testing.T) {
+	f, err := parseSarReader(strings.NewReader(sarOverlay), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	keys := []chartKey{
+		{section: sectionName(SECTION_CPU_UTIL), instance: "all", col: "%usr"},
+		{section: sectionName(SECTION_QLEN_LOADAVG), instance: NO_INSTANCE, col: "runq-sz"},
+	}
+
+	data, err := f.makeChartData(keys, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []time.Time{
+		time.Date(2018, 3, 14, 12, 10, 1, 0, time.UTC),
+		time.Date(2018, 3, 14, 12, 20, 1, 0, time.UTC),
+		time.Date(2018, 3, 14, 12, 30, 1, 0, time.UTC),
+	}, data.times)
+	assert.Equal(t, 3, len(data.labels))
+	if !assert.Equal(t, 2, len(data.series)) {
+		return
+	}
+	assert.Equal(t, 1.5, data.series[0].values[0])
+	assert.Equal(t, 2.5, data.series[0].values[1])
+	assert.True(t, math.IsNaN(data.series[0].values[2]))
+	assert.True(t, math.IsNaN(data.series[1].values[0]))
+	assert.Equal(t, []float64{2, 3}, data.series[1].values[1:])
+	assert.NotEqual(t, data.series[0].color, data.series[1].color)
+	assert.False(t, data.series[1].right)
+
+	data, err = f.makeChartData(keys, true)
+	assert.NoError(t, err)
+	assert.False(t, data.series[0].right)
+	assert.True(t, data.series[1].right)
+
+	_, err = f.makeChartData([]chartKey{{section: sectionName(SECTION_CPU_UTIL), instance: "7", col: "%usr"}}, false)
+	assert.Error(t, err)
+}
+
+func TestMakeChartPoints(t *testing.T) {
+	labels := []string{"a", "b", "c", "d"}
+	series := []chartSeries{
+		{values: []float64{0, 1, 2, 3}, color: termui.ColorGreen},
+		{values: []float64{300, 200, 100, 0}, color: termui.ColorYellow},
+	}
+	colors := func(points [][]termui.Cell) map[termui.Attribute]bool {
+		found := map[termui.Attribute]bool{}
+		for _, row := range points {
+			for _, cell := range row {
+				found[cell.Fg] = true
+			}
+		}
+		return found
+	}
+
+	points, _ := makeChartPoints(40, 8, labels, series)
+	assert.Equal(t, 8, len(points))
+	assert.Equal(t, map[termui.Attribute]bool{AXIS_FG: true, termui.ColorGreen: true, termui.ColorYellow: true}, colors(points))
+	// shared: the small series stays on the bottom row
+	assert.Equal(t, termui.ColorGreen, points[5][7].Fg)
+	assert.Equal(t, "0.00", strings.TrimSpace(cellsText(points[5][:6])))
+
+	series[1].right = true
+	points, _ = makeChartPoints(40, 8, labels, series)
+	// dual: both span the height, the right axis is labelled
+	assert.Equal(t, termui.ColorGreen, points[0][6].Fg)
+	assert.Equal(t, termui.VDASH, points[0][40-7].Ch)
+	assert.Equal(t, "0.00", strings.TrimSpace(cellsText(points[5][40-6:])))
+}
+
+func cellsText(cells []termui.Cell) string {
+	var text []rune
+	for _, cell := range cells {
+		text = append(text, cell.Ch)
+	}
+	return string(text)
+}
+
+func TestChartDataCursor(t *testing.T) {
+	data := &chartData{
+		times: makeTimes(5),
+		series: []chartSeries{
+			{values: []float64{3, math.NaN(), 9, 1, 4}},
+			{values: []float64{100, -100, 0, 0, 0}},
+		},
+	}
+	assert.Equal(t, 2, data.timeIndex(data.times[2]))
+	assert.Equal(t, 3, data.timeIndex(data.times[2].Add(time.Second)))
+	assert.Equal(t, 4, data.timeIndex(data.times[4].Add(time.Hour)))
+
+	// the first series decides
+	assert.Equal(t, 2, data.extreme(true))
+	assert.Equal(t, 3, data.extreme(false))
+	data.series[0].values = []float64{math.NaN(), math.NaN(), math.NaN(), math.NaN(), math.NaN()}
+	assert.Equal(t, -1, data.extreme(true))
+}
+
+func TestMarkChartCursor(t *testing.T) {
+	series := []chartSeries{{name: "%usr", values: []float64{1, 2, math.NaN(), 4}, decimals: 2, color: termui.ColorGreen}}
+	assert.Contains(t, makeReadout("12:00:01", series, 1), "%usr 2.00")
+	assert.Contains(t, makeReadout("12:00:01", series, 2), "%usr -")
+
+	points, _ := makeChartPoints(20, 6, []string{"a", "b", "c", "d"}, series)
+	markChartCursor(points, 2, 1)
+	// 4.00 labels the axis, the samples 2 and 3 share the second cell
+	assert.Equal(t, CURSOR_AXIS_MARKER, points[4][6].Ch)
+	assert.Equal(t, termui.HDASH, points[4][5].Ch)
+	cursorCells := 0
+	for y := 0; y < 4; y++ {
+		if CURSOR_LINE_MARKER == points[y][6].Ch {
+			cursorCells++
+		}
+	}
+	assert.Equal(t, 3, cursorCells)
+
+	// out of the chart
+	points, _ = makeChartPoints(20, 6, []string{"a", "b", "c", "d"}, series)
+	markChartCursor(points, 100, 1)
+	for _, row := range points {
+		for _, cell := range row {
+			assert.NotEqual(t, CURSOR_AXIS_MARKER, cell.Ch)
+		}
+	}
+}
+
+func TestMakeChartPointsDownsampling(t *testing.T) {
+	assert.Equal(t, 1, chartBucket(20, 10))
+	assert.Equal(t, 2, chartBucket(21, 10))
+	assert.Equal(t, 50, chartBucket(1000, 10))
+
+	values := make([]float64, 1000)
+	labels := make([]string, 1000)
+	for idx := range labels {
+		labels[idx] = "12:00"
+	}
+	// a single sample spike
+	values[777] = 100
+	points, bucket := makeChartPoints(40, 8, labels, []chartSeries{{values: values, color: termui.ColorGreen}})
+	if !assert.True(t, bucket > 1) {
+		return
+	}
+	top := 0
+	for _, cell := range points[0] {
+		if termui.ColorGreen == cell.Fg {
+			top++
+		}
+	}
+	assert.Equal(t, 1, top)
+	assert.Contains(t, cellsText(points[7]), fmt.Sprintf("%d samples/dot", bucket))
+
+	// the whole range of the bucket is drawn
+	for originX, cell := range points[6] {
+		if termui.ORIGIN == cell.Ch {
+			for y := 0; y < 6; y++ {
+				assert.Equal(t, termui.ColorGreen, points[y][chartColumn(originX, 777, bucket)].Fg)
+			}
+		}
+	}
+}
+
+func TestMakeChartPointsLabels(t *testing.T) {
+	labels := make([]string, 20)
+	values := make([]float64, 20)
+	for idx := range labels {
+		labels[idx] = fmt.Sprintf("%02d", idx)
+		values[idx] = float64(idx)
+	}
+	for _, mode := range []axisMode{{}, {mode: AXIS_ZERO}} {
+		points, bucket := makeChartPoints(40, 8, labels, []chartSeries{{values: values, color: termui.ColorGreen, axis: mode}})
+		originX := -1
+		for x, cell := range points[6] {
+			if termui.ORIGIN == cell.Ch && originX < 0 {
+				originX = x
+			}
+		}
+		if !assert.True(t, originX >= 0) {
+			return
+		}
+		// each label starts over the column of its sample
+		text := []rune(cellsText(points[7]))
+		found := 0
+		for x := originX; x+2 <= len(text); x++ {
+			sample, err := strconv.Atoi(string(text[x : x+2]))
+			if nil != err {
+				continue
+			}
+			assert.Equal(t, chartColumn(originX, sample, bucket), x, mode.String())
+			found++
+			x += 2
+		}
+		assert.True(t, found > 1, mode.String())
+	}
+}
@@ -0,0 +1,568 @@
+package sarsar
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// binary data files written by sadc (/var/log/sa/saDD), see sa.h in sysstat. Only the format of
+// sysstat 11.7.1 and later is read: the older ones (0x2171 of sysstat 10.1 in RHEL 7, up to 0x2175 of
+// sysstat 11.6) lay out their headers and statistics otherwise, and sadf -c converts them.
+
+const (
+	SYSSTAT_MAGIC         = 0xd596
+	SYSSTAT_MAGIC_SWAPPED = 0x96d5
+
+	FILE_MAGIC_SIZE   = 84 // magic, format, version, pad[48], header_size, upad[3], hdr_types_nr[3]
+	FILE_HEADER_MIN   = 327
+	FILE_ACTIVITY_MIN = 24
+	RECORD_HEADER_MIN = 24
+	EXTRA_DESC_SIZE   = 24
+	UTSNAME_LEN       = 65
+	MAX_COMMENT_LEN   = 64
+	MAX_IFACE_LEN     = 16
+	C_DUPLEX_FULL     = 2
+
+	// the bounds sysstat itself checks, a damaged file must not make us allocate gigabytes
+	MAX_FILE_HEADER_SIZE   = 8192
+	MAX_FILE_ACTIVITY_SIZE = 1024
+	MAX_RECORD_HEADER_SIZE = 512
+	MAX_ITEM_STRUCT_SIZE   = 1024
+	MAX_NR_ACT             = 256
+	NR_MAX                 = 65536 * 4096
+	NR2_MAX                = 128
+
+	// sysstat 11.7.1 and later, the structures of older formats are laid out differently
+	FORMAT_MAGIC_OLDEST = 0x2176
+	FORMAT_MAGIC_NEWEST = 0x2176
+)
+
+const (
+	R_STATS      = 1
+	R_RESTART    = 2
+	R_LAST_STATS = 3
+	R_COMMENT    = 4
+)
+
+const (
+	A_CPU     = 1
+	A_PCSW    = 2
+	A_SWAP    = 4
+	A_PAGE    = 5
+	A_IO      = 6
+	A_MEMORY  = 7
+	A_QUEUE   = 9
+	A_DISK    = 11
+	A_NET_DEV = 12
+)
+
+type sadcActivity struct {
+	id    uint32
+	nr    int
+	nr2   int
+	hasNr bool
+	size  int
+}
+
+type sadcSample struct {
+	uptime uint64 // in 1/100 s
+	time   time.Time
+	stats  map[uint32][][]byte
+}
+
+// sadcDecoder turns two consecutive samples of one activity into sar-like rows,
+// keyed by instance when the activity has several of them
+type sadcDecoder struct {
+	sectionId  int
+	size       int
+	perCpu     bool // one item for "all" followed by one per CPU
+	headerSegs []string
+	decimals   []int // of the values, 2 if not given
+	decode     func(order binary.ByteOrder, prev, curr []byte, itv float64) []float64
+	// instance names the device of an item, items are matched by it as devices come and go
+	instance func(order binary.ByteOrder, item []byte) string
+}
+
+var sadcDecoders = map[uint32]sadcDecoder{
+	A_CPU: {
+		sectionId:  SECTION_CPU_UTIL,
+		size:       80,
+		perCpu:     true,
+		headerSegs: []string{"CPU", "%usr", "%nice", "%sys", "%iowait", "%steal", "%irq", "%soft", "%guest", "%gnice", "%idle"},
+		decode:     decodeSadcCpu,
+	},
+	A_PCSW: {
+		sectionId:  SECTION_TASK_CREATION_AND_SYS_SWITCH,
+		size:       16,
+		headerSegs: []string{"proc/s", "cswch/s"},
+		decode: func(order binary.ByteOrder, prev, curr []byte, itv float64) []float64 {
+			return sadcRates(order, prev, curr, itv, 1, 0)
+		},
+	},
+	A_SWAP: {
+		sectionId:  SECTION_SWAPPING,
+		size:       16,
+		headerSegs: []string{"pswpin/s", "pswpout/s"},
+		decode: func(order binary.ByteOrder, prev, curr []byte, itv float64) []float64 {
+			return sadcRates(order, prev, curr, itv, 0, 1)
+		},
+	},
+	A_PAGE: {
+		sectionId:  SECTION_PAGING,
+		size:       64,
+		headerSegs: []string{"pgpgin/s", "pgpgout/s", "fault/s", "majflt/s", "pgfree/s", "pgscank/s", "pgscand/s", "pgsteal/s", "%vmeff"},
+		decode: func(order binary.ByteOrder, prev, curr []byte, itv float64) []float64 {
+			vals := sadcRates(order, prev, curr, itv, 0, 1, 2, 3, 4, 5, 6, 7)
+			vmeff := float64(0)
+			if scanned := vals[5] + vals[6]; scanned > 0 {
+				vmeff = vals[7] / scanned * 100
+			}
+			return append(vals, vmeff)
+		},
+	},
+	A_IO: {
+		sectionId:  SECTION_IO,
+		size:       56,
+		headerSegs: []string{"tps", "rtps", "wtps", "dtps", "bread/s", "bwrtn/s", "bdscd/s"},
+		decode: func(order binary.ByteOrder, prev, curr []byte, itv float64) []float64 {
+			// stats_io: dk_drive, dk_drive_rio, dk_drive_wio, dk_drive_rblk, dk_drive_wblk, dk_drive_dio, dk_drive_dblk
+			return sadcRates(order, prev, curr, itv, 0, 1, 2, 5, 3, 4, 6)
+		},
+	},
+	A_QUEUE: {
+		sectionId:  SECTION_QLEN_LOADAVG,
+		size:       32,
+		headerSegs: []string{"runq-sz", "plist-sz", "ldavg-1", "ldavg-5", "ldavg-15", "blocked"},
+		decode: func(order binary.ByteOrder, prev, curr []byte, itv float64) []float64 {
+			// stats_queue: ull nr_running, procs_blocked; uint load_avg_1, load_avg_5, load_avg_15, nr_threads
+			return []float64{
+				float64(order.Uint64(curr[0:])),
+				float64(order.Uint32(curr[28:])),
+				float64(order.Uint32(curr[16:])) / 100,
+				float64(order.Uint32(curr[20:])) / 100,
+				float64(order.Uint32(curr[24:])) / 100,
+				float64(order.Uint64(curr[8:])),
+			}
+		},
+	},
+	A_MEMORY: {
+		sectionId: SECTION_MEM_UTIL,
+		size:      136,
+		headerSegs: []string{"kbmemfree", "kbavail", "kbmemused", "%memused", "kbbuffers", "kbcached", "kbcommit", "%commit",
+			"kbactive", "kbinact", "kbdirty", "kbanonpg", "kbslab", "kbkstack", "kbpgtbl", "kbvmused"},
+		decimals: []int{0, 0, 0, 2, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0},
+		decode:   decodeSadcMemory,
+	},
+	A_DISK: {
+		sectionId:  SECTION_BLOCK_DEV,
+		size:       80,
+		headerSegs: []string{"DEV", "tps", "rkB/s", "wkB/s", "dkB/s", "areq-sz", "aqu-sz", "await", "%util"},
+		decode:     decodeSadcDisk,
+		instance: func(order binary.ByteOrder, item []byte) string {
+			// sar names the devices so unless given -p
+			return fmt.Sprintf("dev%d-%d", order.Uint32(item[68:]), order.Uint32(item[72:]))
+		},
+	},
+	A_NET_DEV: {
+		sectionId:  SECTION_NETWORK_DEV,
+		size:       80,
+		headerSegs: []string{"IFACE", "rxpck/s", "txpck/s", "rxkB/s", "txkB/s", "rxcmp/s", "txcmp/s", "rxmcst/s", "%ifutil"},
+		decode:     decodeSadcNetDev,
+		instance: func(order binary.ByteOrder, item []byte) string {
+			return cString(item[60 : 60+MAX_IFACE_LEN])
+		},
+	},
+}
+
+func isSadcMagic(bs []byte) bool {
+	magic := binary.LittleEndian.Uint16(bs)
+	return SYSSTAT_MAGIC == magic || SYSSTAT_MAGIC_SWAPPED == magic
+}
+
+// sadcRates computes per-second rates of the 64-bit counters at the given indexes
+func sadcRates(order binary.ByteOrder, prev, curr []byte, itv float64, idxs ...int) []float64 {
+	var vals []float64
+	for _, idx := range idxs {
+		p, c := order.Uint64(prev[idx*8:]), order.Uint64(curr[idx*8:])
+		vals = append(vals, float64(c-p)/itv)
+	}
+	return vals
+}
+
+func decodeSadcCpu(order binary.ByteOrder, prev, curr []byte, itv float64) []float64 {
+	var delta [10]float64
+	for idx := range delta {
+		p, c := order.Uint64(prev[idx*8:]), order.Uint64(curr[idx*8:])
+		if c > p {
+			delta[idx] = float64(c - p)
+		}
+	}
+	user, nice, sys, idle, iowait, steal, hardirq, softirq, guest, guestNice :=
+		delta[0], delta[1], delta[2], delta[3], delta[4], delta[5], delta[6], delta[7], delta[8], delta[9]
+
+	// guest time is already accounted in user time
+	total := user + nice + sys + idle + iowait + steal + hardirq + softirq
+	if 0 == total {
+		return nil
+	}
+	pct := func(v float64) float64 {
+		return v / total * 100
+	}
+	usr := user - guest
+	if usr < 0 {
+		usr = 0
+	}
+	gnice := nice - guestNice
+	if gnice < 0 {
+		gnice = 0
+	}
+	return []float64{pct(usr), pct(gnice), pct(sys), pct(iowait), pct(steal), pct(hardirq), pct(softirq), pct(guest), pct(guestNice), pct(idle)}
+}
+
+// decodeSadcMemory reads stats_memory: ull frmkb, bufkb, camkb, tlmkb, frskb, tlskb, caskb, comkb,
+// activekb, inactkb, dirtykb, anonpgkb, slabkb, kstackkb, pgtblkb, vmusedkb, availablekb
+func decodeSadcMemory(order binary.ByteOrder, prev, curr []byte, itv float64) []float64 {
+	var kb [17]float64
+	for idx := range kb {
+		kb[idx] = float64(order.Uint64(curr[idx*8:]))
+	}
+	free, buffers, cached, total, swapTotal, commit, slab := kb[0], kb[1], kb[2], kb[3], kb[5], kb[7], kb[12]
+
+	// buffers, cache and slab count as free, as sar has it since sysstat 11.7
+	unused := math.Min(free+buffers+cached+slab, total)
+	memused, pctCommit := float64(0), float64(0)
+	if total > 0 {
+		memused = (total - unused) / total * 100
+	}
+	if total+swapTotal > 0 {
+		pctCommit = commit / (total + swapTotal) * 100
+	}
+	return []float64{free, kb[16], total - unused, memused, buffers, cached, commit, pctCommit,
+		kb[8], kb[9], kb[10], kb[11], slab, kb[13], kb[14], kb[15]}
+}
+
+// decodeSadcDisk reads stats_disk: ull nr_ios, wwn[2], ul rd_sect, wr_sect, dc_sect,
+// uint rd_ticks, wr_ticks, tot_ticks, rq_ticks, dc_ticks, major, minor, part_nr
+func decodeSadcDisk(order binary.ByteOrder, prev, curr []byte, itv float64) []float64 {
+	delta64 := func(offset int) float64 {
+		return float64(order.Uint64(curr[offset:]) - order.Uint64(prev[offset:]))
+	}
+	// the ticks are milliseconds
+	delta32 := func(offset int) float64 {
+		return float64(order.Uint32(curr[offset:]) - order.Uint32(prev[offset:]))
+	}
+	ios := delta64(0)
+	rdSect, wrSect, dcSect := delta64(24), delta64(32), delta64(40)
+	areqSz, await := float64(0), float64(0)
+	if ios > 0 {
+		areqSz = (rdSect + wrSect + dcSect) / ios / 2
+		await = (delta32(48) + delta32(52) + delta32(64)) / ios
+	}
+	return []float64{ios / itv, rdSect / itv / 2, wrSect / itv / 2, dcSect / itv / 2,
+		areqSz, delta32(60) / itv / 1000, await, delta32(56) / itv / 10}
+}
+
+// decodeSadcNetDev reads stats_net_dev: ull rx_packets, tx_packets, rx_bytes, tx_bytes, rx_compressed,
+// tx_compressed, multicast, uint speed (Mb/s), char interface[16], duplex
+func decodeSadcNetDev(order binary.ByteOrder, prev, curr []byte, itv float64) []float64 {
+	vals := sadcRates(order, prev, curr, itv, 0, 1, 2, 3, 4, 5, 6)
+	rx, tx := vals[2], vals[3]
+	ifutil := float64(0)
+	if speed := float64(order.Uint32(curr[56:])) * 1000000; speed > 0 {
+		if C_DUPLEX_FULL == curr[76] {
+			ifutil = math.Max(rx, tx) * 800 / speed
+		} else {
+			ifutil = (rx + tx) * 800 / speed
+		}
+	}
+	return []float64{vals[0], vals[1], rx / 1024, tx / 1024, vals[4], vals[5], vals[6], ifutil}
+}
+
+type sadcReader struct {
+	r     io.Reader
+	order binary.ByteOrder
+}
+
+func (r *sadcReader) read(size int) ([]byte, error) {
+	bs := make([]byte, size)
+	if _, err := io.ReadFull(r.r, bs); nil != err {
+		return nil, err
+	}
+	return bs, nil
+}
+
+// skipExtra skips the extra structures sysstat may chain after a header
+func (r *sadcReader) skipExtra(next uint32) error {
+	for 0 != next {
+		desc, err := r.read(EXTRA_DESC_SIZE)
+		if nil != err {
+			return err
+		}
+		nr, size := r.order.Uint32(desc[0:]), r.order.Uint32(desc[4:])
+		next = r.order.Uint32(desc[8:])
+		if nr > NR_MAX || size > MAX_ITEM_STRUCT_SIZE {
+			return fmt.Errorf("invalid extra structures: %d of %d bytes", nr, size)
+		}
+		for i := uint32(0); i < nr; i++ {
+			if _, err := r.read(int(size)); nil != err {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkSadcCount rejects the item counts of an activity sysstat would not have written
+func checkSadcCount(act sadcActivity, nr int) error {
+	if nr < 0 || nr > NR_MAX {
+		return fmt.Errorf("invalid item count %d of sysstat activity %d", nr, act.id)
+	}
+	return nil
+}
+
+func cString(bs []byte) string {
+	if idx := strings.IndexByte(string(bs), 0); idx >= 0 {
+		return string(bs[:idx])
+	}
+	return string(bs)
+}
+
+func parseSadcReader(in io.Reader, strict bool) (*sarFile, error) {
+	r := &sadcReader{r: in}
+
+	fileMagic := make([]byte, FILE_MAGIC_SIZE)
+	if _, err := io.ReadFull(in, fileMagic[:8]); nil != err {
+		return nil, fmt.Errorf("truncated sysstat data file: %v", err)
+	}
+	if SYSSTAT_MAGIC == binary.LittleEndian.Uint16(fileMagic) {
+		r.order = binary.LittleEndian
+	} else {
+		r.order = binary.BigEndian
+	}
+
+	formatMagic := r.order.Uint16(fileMagic[2:])
+	version := fmt.Sprintf("%d.%d.%d", fileMagic[4], fileMagic[5], fileMagic[6])
+	if formatMagic < FORMAT_MAGIC_OLDEST {
+		return nil, fmt.Errorf("unsupported sysstat data file format 0x%04x (sysstat %s): too old, only 0x%04x of sysstat 11.7.1 and later is read, convert it with \"sadf -c\" first",
+			formatMagic, version, FORMAT_MAGIC_OLDEST)
+	}
+	if formatMagic > FORMAT_MAGIC_NEWEST {
+		return nil, fmt.Errorf("unsupported sysstat data file format 0x%04x (sysstat %s): too new", formatMagic, version)
+	}
+
+	if _, err := io.ReadFull(in, fileMagic[8:]); nil != err {
+		return nil, fmt.Errorf("truncated sysstat data file: %v", err)
+	}
+	headerSize := int(r.order.Uint32(fileMagic[56:]))
+	if headerSize < FILE_HEADER_MIN || headerSize > MAX_FILE_HEADER_SIZE {
+		return nil, fmt.Errorf("invalid sysstat file header size %d", headerSize)
+	}
+
+	header, err := r.read(headerSize)
+	if nil != err {
+		return nil, fmt.Errorf("truncated sysstat file header: %v", err)
+	}
+	actNr := int(r.order.Uint32(header[20:]))
+	actSize := int(r.order.Uint32(header[52:]))
+	recSize := int(r.order.Uint32(header[56:]))
+	if actSize < FILE_ACTIVITY_MIN || actSize > MAX_FILE_ACTIVITY_SIZE || recSize < RECORD_HEADER_MIN || recSize > MAX_RECORD_HEADER_SIZE {
+		return nil, fmt.Errorf("invalid sysstat structure sizes: activity %d, record header %d", actSize, recSize)
+	}
+	if actNr > MAX_NR_ACT {
+		return nil, fmt.Errorf("invalid sysstat activity count %d", actNr)
+	}
+
+	f := newSarFile(strict)
+	cpuNr := int(r.order.Uint32(header[16:]))
+	f.meta = sarMeta{
+		sysname:  cString(header[67 : 67+UTSNAME_LEN]),
+		hostname: cString(header[67+UTSNAME_LEN : 67+2*UTSNAME_LEN]),
+		kernel:   cString(header[67+2*UTSNAME_LEN : 67+3*UTSNAME_LEN]),
+		arch:     cString(header[67+3*UTSNAME_LEN : 67+4*UTSNAME_LEN]),
+		date:     time.Date(1900+int(int32(r.order.Uint32(header[24:]))), time.Month(header[65]+1), int(header[64]), 0, 0, 0, 0, time.UTC),
+		cpus:     cpuNr - 1, // sa_cpu_nr counts "all" too
+	}
+	if f.meta.cpus < 1 {
+		f.meta.cpus = 1
+	}
+	if err := r.skipExtra(r.order.Uint32(header[60:])); nil != err {
+		return nil, fmt.Errorf("damaged sysstat file header: %v", err)
+	}
+
+	var activities []sadcActivity
+	for i := 0; i < actNr; i++ {
+		bs, err := r.read(actSize)
+		if nil != err {
+			return nil, fmt.Errorf("truncated sysstat activity list: %v", err)
+		}
+		act := sadcActivity{
+			id:    r.order.Uint32(bs[0:]),
+			nr:    int(int32(r.order.Uint32(bs[8:]))),
+			nr2:   int(int32(r.order.Uint32(bs[12:]))),
+			hasNr: 0 != r.order.Uint32(bs[16:]),
+			size:  int(int32(r.order.Uint32(bs[20:]))),
+		}
+		if act.size <= 0 || act.size > MAX_ITEM_STRUCT_SIZE {
+			return nil, fmt.Errorf("invalid item size %d of sysstat activity %d", act.size, act.id)
+		}
+		if act.nr2 < 1 || act.nr2 > NR2_MAX {
+			return nil, fmt.Errorf("invalid item count %d of sysstat activity %d", act.nr2, act.id)
+		}
+		if err := checkSadcCount(act, act.nr); nil != err {
+			return nil, err
+		}
+		activities = append(activities, act)
+		if decoder, found := sadcDecoders[act.id]; !found {
+			f.warn("", fmt.Errorf("sysstat activity %d (%d byte items) is not supported, skipped", act.id, act.size))
+		} else if decoder.size != act.size {
+			f.warn("", fmt.Errorf("sysstat activity %d has %d byte items rather than %d, from another sysstat version, skipped",
+				act.id, act.size, decoder.size))
+		}
+	}
+
+	// a damaged record ends the file, what was read so far is kept in lenient mode
+	stop := func(err error) (*sarFile, error) {
+		if err := f.fail(0, "", err); nil != err {
+			return nil, err
+		}
+		return f, nil
+	}
+
+	var prev *sadcSample
+	last := f.meta.date
+	for {
+		bs, err := r.read(recSize)
+		if io.EOF == err {
+			return f, nil
+		}
+		if nil != err {
+			return stop(fmt.Errorf("truncated sysstat record header: %v", err))
+		}
+		if err := r.skipExtra(r.order.Uint32(bs[16:])); nil != err {
+			return stop(fmt.Errorf("damaged sysstat record header: %v", err))
+		}
+
+		// records only carry the time of day, roll the date forward past midnight
+		ts := time.Date(last.Year(), last.Month(), last.Day(), int(bs[21]), int(bs[22]), int(bs[23]), 0, time.UTC)
+		if ts.Before(last) {
+			ts = ts.AddDate(0, 0, 1)
+		}
+		last = ts
+
+		switch bs[20] {
+		case R_RESTART:
+			// new number of CPU, counters start again from zero
+			if _, err := r.read(4); nil != err {
+				return stop(fmt.Errorf("truncated sysstat restart record: %v", err))
+			}
+			f.addRestart(ts)
+			prev = nil
+			continue
+		case R_COMMENT:
+			if _, err := r.read(MAX_COMMENT_LEN); nil != err {
+				return stop(fmt.Errorf("truncated sysstat comment record: %v", err))
+			}
+			continue
+		case R_STATS, R_LAST_STATS:
+		default:
+			return stop(fmt.Errorf("unknown sysstat record type %d", bs[20]))
+		}
+
+		curr := &sadcSample{
+			uptime: r.order.Uint64(bs[0:]),
+			time:   ts,
+			stats:  map[uint32][][]byte{},
+		}
+		for _, act := range activities {
+			nr := act.nr
+			if act.hasNr {
+				nrBs, err := r.read(4)
+				if nil != err {
+					return stop(fmt.Errorf("truncated sysstat record: %v", err))
+				}
+				nr = int(int32(r.order.Uint32(nrBs)))
+				if err := checkSadcCount(act, nr); nil != err {
+					return stop(err)
+				}
+			}
+			var items [][]byte
+			for i := 0; i < nr*act.nr2; i++ {
+				item, err := r.read(act.size)
+				if nil != err {
+					return stop(fmt.Errorf("truncated sysstat record: %v", err))
+				}
+				items = append(items, item)
+			}
+			if decoder, found := sadcDecoders[act.id]; found && decoder.size == act.size {
+				curr.stats[act.id] = items
+			}
+		}
+
+		if nil != prev && curr.uptime > prev.uptime {
+			f.addSadcSample(r.order, prev, curr)
+		}
+		prev = curr
+	}
+}
+
+func (s *sarFile) addSadcSample(order binary.ByteOrder, prev, curr *sadcSample) {
+	itv := float64(curr.uptime-prev.uptime) / 100
+	// in the order of the activities, for that of the sections
+	var ids []int
+	for id := range curr.stats {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		decoder := sadcDecoders[uint32(id)]
+		items := curr.stats[uint32(id)]
+		prevItems := prev.stats[uint32(id)]
+		prevByInstance := map[string][]byte{}
+		if nil != decoder.instance {
+			for _, item := range prevItems {
+				prevByInstance[decoder.instance(order, item)] = item
+			}
+		}
+		for idx, item := range items {
+			var segs []string
+			var prevItem []byte
+			if nil != decoder.instance {
+				instance := decoder.instance(order, item)
+				prevItem = prevByInstance[instance]
+				segs = append(segs, instance)
+			} else if idx < len(prevItems) {
+				prevItem = prevItems[idx]
+				if decoder.perCpu {
+					instance := "all"
+					if idx > 0 {
+						instance = fmt.Sprint(idx - 1)
+					}
+					segs = append(segs, instance)
+				}
+			}
+			// a device new in this sample has no rates yet
+			if nil == prevItem {
+				continue
+			}
+			vals := decoder.decode(order, prevItem, item, itv)
+			if nil == vals {
+				continue
+			}
+
+			for col, val := range vals {
+				decimals := 2
+				if col < len(decoder.decimals) {
+					decimals = decoder.decimals[col]
+				}
+				segs = append(segs, strconv.FormatFloat(val, 'f', decimals, 64))
+			}
+			s.addRecord(decoder.sectionId, decoder.headerSegs, curr.time, segs)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package sarsar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+type sadcWriter struct {
+	bytes.Buffer
+}
+
+func (w *sadcWriter) put(vals ...interface{}) {
+	for _, val := range vals {
+		binary.Write(&w.Buffer, binary.LittleEndian, val)
+	}
+}
+
+func (w *sadcWriter) putString(s string, size int) {
+	bs := make([]byte, size)
+	copy(bs, s)
+	w.Write(bs)
+}
+
+// putHeader writes the file_magic and file_header of a file with actNr activities
+func (w *sadcWriter) putHeader(formatMagic uint16, actNr uint32) {
+	// file_magic
+	w.put(uint16(SYSSTAT_MAGIC), formatMagic, []byte{12, 2, 0, 0}, make([]byte, 48))
+	w.put(uint32(FILE_HEADER_MIN+1), [3]uint32{}, [3]uint32{})
+
+	// file_header
+	w.put(uint64(1521000000), uint64(100), uint32(3) /* all + 2 CPU */, actNr, int32(118))
+	w.put([3]uint32{}, [3]uint32{}, uint32(36), uint32(24), uint32(0))
+	w.put(uint8(14), uint8(2), int8(8))
+	w.putString("Linux", UTSNAME_LEN)
+	w.putString("db01", UTSNAME_LEN)
+	w.putString("4.15.0-20-generic", UTSNAME_LEN)
+	w.putString("x86_64", UTSNAME_LEN)
+	w.put(uint8(0))
+}
+
+func makeSadcFile(formatMagic uint16) []byte {
+	w := &sadcWriter{}
+	w.putHeader(formatMagic, 2)
+
+	// file_activity list: CPU, PCSW
+	w.put(uint32(A_CPU), uint32(0), int32(3), int32(1), int32(1), int32(80), [3]uint32{})
+	w.put(uint32(A_PCSW), uint32(0), int32(1), int32(1), int32(0), int32(16), [3]uint32{})
+
+	record := func(uptime uint64, hour, minute uint8, cpu [3][10]uint64, cswch, procs uint64) {
+		w.put(uptime, uint64(0), uint32(0), uint8(R_STATS), hour, minute, uint8(1))
+		w.put(int32(3), cpu)
+		w.put(cswch, procs)
+	}
+	record(100, 23, 50, [3][10]uint64{}, 1000, 10)
+	record(60100, 0, 0, [3][10]uint64{
+		{200, 0, 100, 1600, 100},
+		{150, 0, 50, 700, 100},
+		{50, 0, 50, 900, 0},
+	}, 61000, 40)
+	w.put(uint64(60200), uint64(0), uint32(0), uint8(R_RESTART), uint8(0), uint8(1), uint8(1), uint32(3))
+	// the counters start again from zero after the restart
+	record(100, 0, 2, [3][10]uint64{}, 0, 0)
+	record(6100, 0, 3, [3][10]uint64{
+		{300, 0, 100, 1500, 100},
+		{150, 0, 50, 700, 100},
+		{150, 0, 50, 800, 0},
+	}, 12000, 6)
+
+	return w.Bytes()
+}
+
+func TestParseSadcFile(t *testing.T) {
+	f, err := parseSarInput(bytes.NewReader(makeSadcFile(FORMAT_MAGIC_NEWEST)), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "db01 | Linux 4.15.0-20-generic | x86_64 | 2 CPU | 2018-03-14", f.meta.String())
+
+	_, values, err := f.getDataSeriesByName(sectionName(SECTION_CPU_UTIL), "all", "%usr")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{10, 15}, values)
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_CPU_UTIL), "1", "%idle")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{90, 80}, values)
+
+	name := sectionName(SECTION_TASK_CREATION_AND_SYS_SWITCH)
+	labels, values, err := f.getDataSeriesByName(name, NO_INSTANCE, "cswch/s")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{100, 200}, values)
+	assert.Equal(t, []string{"Mar 15 00:00:01", "Mar 15 00:03:01"}, labels)
+	_, values, err = f.getDataSeriesByName(name, NO_INSTANCE, "proc/s")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.05, 0.1}, values)
+
+	// no rates across the restart, which splits the series
+	series, err := f.getSeries(name, NO_INSTANCE)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []sarBreak{{index: 1, restart: true}}, series.breaks)
+	}
+}
+
+func TestParseSadcFileUnsupportedFormat(t *testing.T) {
+	_, err := parseSarInput(bytes.NewReader(makeSadcFile(0x2171)), true)
+	assert.EqualError(t, err, "unsupported sysstat data file format 0x2171 (sysstat 12.2.0): too old, only 0x2176 of sysstat 11.7.1 and later is read, convert it with \"sadf -c\" first")
+
+	// sysstat 10.3.1 to 11.6 lay out their structures otherwise
+	_, err = parseSarInput(bytes.NewReader(makeSadcFile(0x2175)), true)
+	assert.EqualError(t, err, "unsupported sysstat data file format 0x2175 (sysstat 12.2.0): too old, only 0x2176 of sysstat 11.7.1 and later is read, convert it with \"sadf -c\" first")
+
+	_, err = parseSarInput(bytes.NewReader(makeSadcFile(0x2190)), true)
+	assert.EqualError(t, err, "unsupported sysstat data file format 0x2190 (sysstat 12.2.0): too new")
+}
+
+// makeSadcCountFile has one activity of nr2 items of the given size, a count in each record and
+// a record for each count given
+func makeSadcCountFile(nr2, size int32, counts ...int32) []byte {
+	w := &sadcWriter{}
+	w.putHeader(FORMAT_MAGIC_NEWEST, 1)
+	w.put(uint32(A_PCSW), uint32(0), int32(1), nr2, int32(1), size, [3]uint32{})
+	for idx, count := range counts {
+		w.put(uint64(100+6000*idx), uint64(0), uint32(0), uint8(R_STATS), uint8(10), uint8(idx), uint8(0))
+		w.put(count)
+		for i := int32(0); i < count; i++ {
+			w.put(uint64(1000*(idx+1)), uint64(10*(idx+1)))
+		}
+	}
+	return w.Bytes()
+}
+
+func TestParseSadcFileCorrupt(t *testing.T) {
+	// the activity list is not read past, whatever the mode
+	_, err := parseSarInput(bytes.NewReader(makeSadcCountFile(1, -1)), false)
+	assert.EqualError(t, err, "invalid item size -1 of sysstat activity 2")
+	_, err = parseSarInput(bytes.NewReader(makeSadcCountFile(1, 1<<30)), false)
+	assert.EqualError(t, err, "invalid item size 1073741824 of sysstat activity 2")
+	_, err = parseSarInput(bytes.NewReader(makeSadcCountFile(-1, 16)), false)
+	assert.EqualError(t, err, "invalid item count -1 of sysstat activity 2")
+
+	bs := makeSadcCountFile(1, 16)
+	binary.LittleEndian.PutUint32(bs[56:], 0xffffffff)
+	_, err = parseSarInput(bytes.NewReader(bs), false)
+	assert.EqualError(t, err, "invalid sysstat file header size 4294967295")
+
+	// a damaged record ends the file, the samples before it are kept in lenient mode
+	bs = makeSadcCountFile(1, 16, 1, 1, -5, 1)
+	f, err := parseSarInput(bytes.NewReader(bs), false)
+	if assert.NoError(t, err) {
+		_, values, err := f.getDataSeriesByName(sectionName(SECTION_TASK_CREATION_AND_SYS_SWITCH), NO_INSTANCE, "cswch/s")
+		assert.NoError(t, err)
+		assert.Equal(t, []float64{16.67}, values)
+		if assert.Equal(t, 1, len(f.diagnostics)) {
+			assert.Equal(t, "invalid item count -5 of sysstat activity 2", f.diagnostics[0].String())
+		}
+	}
+	_, err = parseSarInput(bytes.NewReader(bs), true)
+	assert.EqualError(t, err, "invalid item count -5 of sysstat activity 2")
+
+	// extra structures of 4 GB chained to a record
+	w := &sadcWriter{}
+	w.Write(makeSadcCountFile(1, 16, 1))
+	w.put(uint64(6100), uint64(0), uint32(1), uint8(R_STATS), uint8(10), uint8(1), uint8(0))
+	w.put(uint32(0xffffffff), uint32(16), uint32(0), [3]uint32{})
+	_, err = parseSarInput(bytes.NewReader(w.Bytes()), true)
+	assert.EqualError(t, err, "damaged sysstat record header: invalid extra structures: 4294967295 of 16 bytes")
+}
+
+func makeSadcDeviceFile() []byte {
+	w := &sadcWriter{}
+	w.putHeader(FORMAT_MAGIC_NEWEST, 5)
+
+	// file_activity list: memory, disks and interfaces, then an unsupported one and one of another size
+	w.put(uint32(A_MEMORY), uint32(0), int32(1), int32(1), int32(0), int32(136), [3]uint32{})
+	w.put(uint32(A_DISK), uint32(0), int32(2), int32(1), int32(1), int32(80), [3]uint32{})
+	w.put(uint32(A_NET_DEV), uint32(0), int32(1), int32(1), int32(1), int32(80), [3]uint32{})
+	w.put(uint32(3), uint32(0), int32(1), int32(1), int32(0), int32(8), [3]uint32{})
+	w.put(uint32(A_PCSW), uint32(0), int32(1), int32(1), int32(0), int32(24), [3]uint32{})
+
+	disk := func(minor uint32, ios, rdSect, wrSect uint64, rdTicks, wrTicks, totTicks, rqTicks uint32) {
+		w.put(ios, [2]uint64{}, rdSect, wrSect, uint64(0), rdTicks, wrTicks, totTicks, rqTicks, uint32(0), uint32(8), minor, uint32(0))
+	}
+	iface := func(rxPackets, txPackets, rxBytes, txBytes uint64) {
+		w.put(rxPackets, txPackets, rxBytes, txBytes, [3]uint64{}, uint32(1) /* Mb/s */)
+		w.putString("eth0", MAX_IFACE_LEN)
+		w.put(uint8(C_DUPLEX_FULL), [3]uint8{})
+	}
+
+	w.put(uint64(100), uint64(0), uint32(0), uint8(R_STATS), uint8(10), uint8(0), uint8(0))
+	w.put(make([]uint64, 17))
+	w.put(int32(1))
+	disk(0, 100, 0, 0, 0, 0, 0, 0)
+	w.put(int32(1))
+	iface(0, 0, 0, 0)
+	w.put(uint64(0), make([]byte, 24))
+
+	w.put(uint64(1100), uint64(0), uint32(0), uint8(R_STATS), uint8(10), uint8(0), uint8(10))
+	// frmkb, bufkb, camkb, tlmkb, frskb, tlskb, caskb, comkb, activekb, inactkb, dirtykb, anonpgkb,
+	// slabkb, kstackkb, pgtblkb, vmusedkb, availablekb
+	w.put([]uint64{1000, 100, 400, 4000, 500, 1000, 0, 2500, 800, 300, 10, 600, 500, 20, 30, 40, 2000})
+	// a disk showing up has no rates yet
+	w.put(int32(2))
+	disk(0, 200, 2000, 2000, 300, 200, 5000, 1000)
+	disk(16, 50, 0, 0, 0, 0, 0, 0)
+	w.put(int32(1))
+	iface(1000, 500, 1024000, 512000)
+	w.put(uint64(0), make([]byte, 24))
+
+	return w.Bytes()
+}
+
+func TestParseSadcFileDevices(t *testing.T) {
+	f, err := parseSarInput(bytes.NewReader(makeSadcDeviceFile()), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for col, expected := range map[string]float64{"kbmemfree": 1000, "kbavail": 2000, "kbmemused": 2000, "%memused": 50, "%commit": 50, "kbslab": 500} {
+		_, values, err := f.getDataSeriesByName(sectionName(SECTION_MEM_UTIL), NO_INSTANCE, col)
+		assert.NoError(t, err, col)
+		assert.Equal(t, []float64{expected}, values, col)
+	}
+	for col, expected := range map[string]float64{"tps": 10, "rkB/s": 100, "wkB/s": 100, "areq-sz": 20, "aqu-sz": 0.1, "await": 5, "%util": 50} {
+		_, values, err := f.getDataSeriesByName(sectionName(SECTION_BLOCK_DEV), "dev8-0", col)
+		assert.NoError(t, err, col)
+		assert.Equal(t, []float64{expected}, values, col)
+	}
+	_, _, err = f.getDataSeriesByName(sectionName(SECTION_BLOCK_DEV), "dev8-16", "tps")
+	assert.Error(t, err)
+	for col, expected := range map[string]float64{"rxpck/s": 100, "txpck/s": 50, "rxkB/s": 100, "txkB/s": 50, "%ifutil": 81.92} {
+		_, values, err := f.getDataSeriesByName(sectionName(SECTION_NETWORK_DEV), "eth0", col)
+		assert.NoError(t, err, col)
+		assert.Equal(t, []float64{expected}, values, col)
+	}
+
+	var diags []string
+	for _, diag := range f.diagnostics {
+		diags = append(diags, diag.String())
+	}
+	assert.Equal(t, []string{
+		"sysstat activity 3 (8 byte items) is not supported, skipped",
+		"sysstat activity 2 has 24 byte items rather than 16, from another sysstat version, skipped",
+	}, diags)
+}
@@ -0,0 +1,285 @@
+package sarsar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// "sadf -d" (database, semicolon separated) and "sadf -p" (one metric per line, tab separated) output
+
+const (
+	SADF_DB_SEPARATOR   = ";"
+	SADF_PPC_SEPARATOR  = "\t"
+	SADF_NO_DEVICE      = "-"
+	SADF_RESTART_MARKER = "LINUX-RESTART"
+)
+
+// parseSadfTime parses "2018-03-14 00:10:01 UTC", sadf writes UTC unless asked for local time
+func parseSadfTime(s string) (time.Time, error) {
+	segs := strings.Fields(s)
+	if len(segs) < 2 {
+		return time.Time{}, fmt.Errorf("invalid sadf timestamp: %s", s)
+	}
+	ts, err := time.Parse("2006-01-02 15:04:05", segs[0]+" "+segs[1])
+	if nil != err {
+		return time.Time{}, fmt.Errorf("invalid sadf timestamp: %s", s)
+	}
+	return ts, nil
+}
+
+// normalizeSadfInstance maps sadf's CPU naming ("-1", "cpu0") onto sar's ("all", "0")
+func normalizeSadfInstance(instanceColumn, instance string) string {
+	if "CPU" != instanceColumn {
+		return instance
+	}
+	if "-1" == instance {
+		return "all"
+	}
+	return strings.TrimPrefix(instance, "cpu")
+}
+
+func isSadfDb(head string) bool {
+	line := strings.SplitN(head, "\n", 2)[0]
+	if strings.HasPrefix(line, "# hostname;") {
+		return true
+	}
+	segs := strings.Split(line, SADF_DB_SEPARATOR)
+	if len(segs) < 4 {
+		return false
+	}
+	_, err := parseSadfTime(segs[2])
+	return nil == err
+}
+
+func isSadfPpc(head string) bool {
+	segs := strings.Split(strings.SplitN(head, "\n", 2)[0], SADF_PPC_SEPARATOR)
+	if len(segs) != 6 {
+		return false
+	}
+	_, err := parseSadfTime(segs[2])
+	return nil == err
+}
+
+func (s *sarFile) setSadfMeta(hostname string, ts time.Time) {
+	if "" == s.meta.hostname {
+		s.meta.hostname = hostname
+	}
+	if s.meta.date.IsZero() {
+		s.meta.date = time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func parseSadfDbHeader(line string) (int, []string, error) {
+	segs := strings.Split(strings.TrimSpace(strings.TrimPrefix(line, "#")), SADF_DB_SEPARATOR)
+	if len(segs) < 4 {
+		return 0, nil, fmt.Errorf("sadf header should have 4 fields at least, but line was \"%s\"", line)
+	}
+	sectionId, found := matchSectionHeader(segs[3:])
+	if !found {
+		return 0, nil, fmt.Errorf("unrecognized section header: \"%v\"", line)
+	}
+	return sectionId, segs[3:], nil
+}
+
+func (s *sarFile) addSadfDbData(sectionId int, headerSegs []string, line string) error {
+	segs := strings.Split(line, SADF_DB_SEPARATOR)
+	if nil == headerSegs {
+		return fmt.Errorf("data line before any sadf header: \"%v\"", line)
+	}
+	if len(segs)-3 != len(headerSegs) {
+		return fmt.Errorf("data line has different segments count with header line: \"%v\"", line)
+	}
+	ts, err := parseSadfTime(segs[2])
+	if nil != err {
+		return err
+	}
+	s.setSadfMeta(segs[0], ts)
+
+	segs = segs[3:]
+	if instanceColumn := sectionRegistry[sectionId].InstanceColumn; NO_INSTANCE != instanceColumn {
+		for idx := range headerSegs {
+			if instanceColumn == headerSegs[idx] {
+				segs[idx] = normalizeSadfInstance(instanceColumn, segs[idx])
+			}
+		}
+	}
+	s.addRecord(sectionId, headerSegs, ts, segs)
+	return nil
+}
+
+func parseSadfDb(r io.Reader, strict bool) (*sarFile, error) {
+	f := newSarFile(strict)
+
+	buf := bufio.NewScanner(r)
+	buf.Buffer(make([]byte, 64*1024), 1024*1024)
+	sectionId := 0
+	skipSection := false
+	var headerSegs []string
+	for lineNo := 1; buf.Scan(); lineNo++ {
+		f.lineNo = lineNo
+		line := buf.Text()
+		if "" == strings.TrimSpace(line) {
+			continue
+		}
+
+		var err error
+		segs := strings.Split(line, SADF_DB_SEPARATOR)
+		if strings.HasPrefix(line, "#") {
+			// "# hostname;interval;timestamp;CPU;%usr;..."
+			sectionId, headerSegs, err = parseSadfDbHeader(line)
+			skipSection = nil != err
+		} else if len(segs) >= 4 && strings.HasPrefix(segs[3], SADF_RESTART_MARKER) {
+			var ts time.Time
+			if ts, err = parseSadfTime(segs[2]); nil == err {
+				f.addRestart(ts)
+			}
+		} else if !skipSection {
+			err = f.addSadfDbData(sectionId, headerSegs, line)
+		}
+		if nil != err {
+			if err := f.fail(lineNo, line, err); nil != err {
+				return nil, err
+			}
+		}
+	}
+	if err := buf.Err(); nil != err {
+		return nil, err
+	}
+	return f, nil
+}
+
+// matchSadfPpcHeader finds the section starting with the given columns,
+// trying each instance column in front of them for device lines
+func matchSadfPpcHeader(device string, cols []string) (int, string, bool) {
+	if SADF_NO_DEVICE == device {
+		sectionId, found := matchSectionHeader(cols)
+		return sectionId, NO_INSTANCE, found
+	}
+	for _, instanceColumn := range instanceColumns() {
+		if sectionId, found := matchSectionHeader(append([]string{instanceColumn}, cols...)); found {
+			return sectionId, instanceColumn, true
+		}
+	}
+	return 0, NO_INSTANCE, false
+}
+
+// sadfPpcGroup collects the consecutive lines of one timestamp and device
+type sadfPpcGroup struct {
+	lineNo int
+	ts     time.Time
+	device string
+	fields []string
+	values []string
+}
+
+func (s *sarFile) addSadfPpcGroup(group *sadfPpcGroup) error {
+	// several activities may follow each other with the same device, split them where a section header starts
+	var starts []int
+	for idx := range group.fields {
+		if 0 == idx {
+			starts = append(starts, idx)
+		} else if idx+1 < len(group.fields) {
+			if _, _, found := matchSadfPpcHeader(group.device, group.fields[idx:idx+2]); found {
+				starts = append(starts, idx)
+			}
+		}
+	}
+	starts = append(starts, len(group.fields))
+
+	for i := 0; i+1 < len(starts); i++ {
+		fields := group.fields[starts[i]:starts[i+1]]
+		values := group.values[starts[i]:starts[i+1]]
+		sectionId, instanceColumn, found := matchSadfPpcHeader(group.device, fields)
+		if !found {
+			return fmt.Errorf("unrecognized sadf fields: %v", fields)
+		}
+
+		headerSegs := fields
+		segs := values
+		if NO_INSTANCE != instanceColumn {
+			headerSegs = append([]string{instanceColumn}, fields...)
+			segs = append([]string{normalizeSadfInstance(instanceColumn, group.device)}, values...)
+		}
+		s.addRecord(sectionId, headerSegs, group.ts, segs)
+	}
+	return nil
+}
+
+func (s *sarFile) flushSadfPpcGroup(group *sadfPpcGroup) error {
+	s.lineNo = group.lineNo
+	if err := s.addSadfPpcGroup(group); nil != err {
+		return s.fail(group.lineNo, strings.Join(group.fields, " "), err)
+	}
+	return nil
+}
+
+func parseSadfPpc(r io.Reader, strict bool) (*sarFile, error) {
+	f := newSarFile(strict)
+
+	buf := bufio.NewScanner(r)
+	var group *sadfPpcGroup
+	for lineNo := 1; buf.Scan(); lineNo++ {
+		f.lineNo = lineNo
+		line := buf.Text()
+		if "" == strings.TrimSpace(line) {
+			continue
+		}
+
+		// hostname, interval, timestamp, device, field, value
+		segs := strings.Split(line, SADF_PPC_SEPARATOR)
+		if len(segs) >= 4 && strings.HasPrefix(segs[3], SADF_RESTART_MARKER) {
+			if ts, err := parseSadfTime(segs[2]); nil == err {
+				f.addRestart(ts)
+			}
+			continue
+		}
+		var ts time.Time
+		var err error
+		if len(segs) != 6 {
+			err = fmt.Errorf("sadf line should have 6 fields, but line was \"%s\"", line)
+		} else {
+			ts, err = parseSadfTime(segs[2])
+		}
+		if nil != err {
+			if err := f.fail(lineNo, line, err); nil != err {
+				return nil, err
+			}
+			continue
+		}
+		f.setSadfMeta(segs[0], ts)
+
+		if nil != group && (!group.ts.Equal(ts) || group.device != segs[3] || contains(group.fields, segs[4])) {
+			if err := f.flushSadfPpcGroup(group); nil != err {
+				return nil, err
+			}
+			group = nil
+		}
+		if nil == group {
+			group = &sadfPpcGroup{lineNo: lineNo, ts: ts, device: segs[3]}
+		}
+		group.fields = append(group.fields, segs[4])
+		group.values = append(group.values, segs[5])
+	}
+	if err := buf.Err(); nil != err {
+		return nil, err
+	}
+
+	if nil != group {
+		if err := f.flushSadfPpcGroup(group); nil != err {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func contains(segs []string, seg string) bool {
+	for _, s := range segs {
+		if s == seg {
+			return true
+		}
+	}
+	return false
+}
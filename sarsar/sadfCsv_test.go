@@ -0,0 +1,85 @@
+package sarsar
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+const sadfDb = `# hostname;interval;timestamp;CPU;%usr;%nice;%sys;%iowait;%steal;%irq;%soft;%guest;%gnice;%idle
+db01;600;2018-03-14 00:10:01 UTC;-1;1.50;0.00;0.50;3.00;0.00;0.00;0.00;0.00;0.00;95.00
+db01;600;2018-03-14 00:10:01 UTC;0;2.00;0.00;1.00;6.00;0.00;0.00;0.00;0.00;0.00;91.00
+db01;600;2018-03-14 00:20:01 UTC;-1;2.50;0.00;0.50;1.00;0.00;0.00;0.00;0.00;0.00;96.00
+db01;600;2018-03-14 00:20:01 UTC;0;3.00;0.00;1.00;2.00;0.00;0.00;0.00;0.00;0.00;94.00
+# hostname;interval;timestamp;proc/s;cswch/s
+db01;600;2018-03-14 00:10:01 UTC;0.50;100.00
+db01;600;2018-03-14 00:20:01 UTC;0.60;110.00
+`
+
+func TestParseSadfDb(t *testing.T) {
+	f, err := parseSarInput(strings.NewReader(sadfDb), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "db01", f.meta.hostname)
+	assert.Equal(t, time.Date(2018, 3, 14, 0, 0, 0, 0, time.UTC), f.meta.date)
+
+	_, values, err := f.getDataSeriesByName(sectionName(SECTION_CPU_UTIL), "all", "%usr")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1.5, 2.5}, values)
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_CPU_UTIL), "0", "%iowait")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{6, 2}, values)
+
+	labels, values, err := f.getDataSeriesByName(sectionName(SECTION_TASK_CREATION_AND_SYS_SWITCH), NO_INSTANCE, "cswch/s")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{100, 110}, values)
+	assert.Equal(t, []string{"Mar 14 00:10:01", "Mar 14 00:20:01"}, labels)
+}
+
+const sadfPpc = "db01\t600\t2018-03-14 00:10:01 UTC\tall\t%usr\t1.50\n" +
+	"db01\t600\t2018-03-14 00:10:01 UTC\tall\t%nice\t0.00\n" +
+	"db01\t600\t2018-03-14 00:10:01 UTC\tcpu0\t%usr\t2.00\n" +
+	"db01\t600\t2018-03-14 00:10:01 UTC\tcpu0\t%nice\t0.00\n" +
+	"db01\t600\t2018-03-14 00:10:01 UTC\t-\tproc/s\t0.50\n" +
+	"db01\t600\t2018-03-14 00:10:01 UTC\t-\tcswch/s\t100.00\n" +
+	"db01\t600\t2018-03-14 00:10:01 UTC\t-\tpswpin/s\t1.00\n" +
+	"db01\t600\t2018-03-14 00:10:01 UTC\t-\tpswpout/s\t2.00\n" +
+	"db01\t600\t2018-03-14 00:10:01 UTC\tsda\ttps\t5.00\n" +
+	"db01\t600\t2018-03-14 00:10:01 UTC\tsda\trd_sec/s\t8.00\n" +
+	"db01\t600\t2018-03-14 00:20:01 UTC\tall\t%usr\t2.50\n" +
+	"db01\t600\t2018-03-14 00:20:01 UTC\tall\t%nice\t0.00\n" +
+	"db01\t600\t2018-03-14 00:20:01 UTC\tcpu0\t%usr\t3.00\n" +
+	"db01\t600\t2018-03-14 00:20:01 UTC\tcpu0\t%nice\t0.00\n" +
+	"db01\t600\t2018-03-14 00:20:01 UTC\t-\tproc/s\t0.60\n" +
+	"db01\t600\t2018-03-14 00:20:01 UTC\t-\tcswch/s\t110.00\n" +
+	"db01\t600\t2018-03-14 00:20:01 UTC\t-\tpswpin/s\t3.00\n" +
+	"db01\t600\t2018-03-14 00:20:01 UTC\t-\tpswpout/s\t4.00\n" +
+	"db01\t600\t2018-03-14 00:20:01 UTC\tsda\ttps\t6.00\n" +
+	"db01\t600\t2018-03-14 00:20:01 UTC\tsda\trd_sec/s\t16.00\n"
+
+func TestParseSadfPpc(t *testing.T) {
+	f, err := parseSarInput(strings.NewReader(sadfPpc), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "db01", f.meta.hostname)
+	assert.Equal(t, 4 /* cpu, pcsw, swap, dev */, len(f.sections))
+
+	_, values, err := f.getDataSeriesByName(sectionName(SECTION_CPU_UTIL), "0", "%usr")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{2, 3}, values)
+
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_TASK_CREATION_AND_SYS_SWITCH), NO_INSTANCE, "cswch/s")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{100, 110}, values)
+
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_SWAPPING), NO_INSTANCE, "pswpout/s")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{2, 4}, values)
+
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_BLOCK_DEV), "sda", "rd_sec/s")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{8, 16}, values)
+}
@@ -0,0 +1,326 @@
+package sarsar
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSON documents written by "sadf -j"
+
+type sadfJsonDoc struct {
+	Sysstat struct {
+		Hosts []sadfJsonHost `json:"hosts"`
+	} `json:"sysstat"`
+}
+
+type sadfJsonHost struct {
+	Nodename   string                   `json:"nodename"`
+	Sysname    string                   `json:"sysname"`
+	Release    string                   `json:"release"`
+	Machine    string                   `json:"machine"`
+	Cpus       int                      `json:"number-of-cpus"`
+	FileDate   string                   `json:"file-date"`
+	Statistics []map[string]interface{} `json:"statistics"`
+	Restarts   []struct {
+		Boot struct {
+			Date string `json:"date"`
+			Time string `json:"time"`
+		} `json:"boot"`
+	} `json:"restarts"`
+}
+
+type sadfColumn struct {
+	key  string // slash-separated path inside the activity object
+	name string // column name in sar text output
+}
+
+type sadfActivity struct {
+	path        string // slash-separated path of the activity inside a "statistics" entry
+	sectionId   int
+	instanceKey string
+	columns     []sadfColumn
+}
+
+var sadfActivities = []sadfActivity{
+	{path: "cpu-load-all", sectionId: SECTION_CPU_UTIL, instanceKey: "cpu", columns: []sadfColumn{
+		{"cpu", "CPU"}, {"usr", "%usr"}, {"nice", "%nice"}, {"sys", "%sys"}, {"iowait", "%iowait"}, {"steal", "%steal"},
+		{"irq", "%irq"}, {"soft", "%soft"}, {"guest", "%guest"}, {"gnice", "%gnice"}, {"idle", "%idle"},
+	}},
+	{path: "cpu-load", sectionId: SECTION_CPU_UTIL, instanceKey: "cpu", columns: []sadfColumn{
+		{"cpu", "CPU"}, {"user", "%user"}, {"nice", "%nice"}, {"system", "%system"}, {"iowait", "%iowait"}, {"steal", "%steal"},
+		{"idle", "%idle"},
+	}},
+	{path: "process-and-context-switch", sectionId: SECTION_TASK_CREATION_AND_SYS_SWITCH, columns: []sadfColumn{
+		{"proc", "proc/s"}, {"cswch", "cswch/s"},
+	}},
+	{path: "swap-pages", sectionId: SECTION_SWAPPING, columns: []sadfColumn{
+		{"pswpin", "pswpin/s"}, {"pswpout", "pswpout/s"},
+	}},
+	{path: "paging", sectionId: SECTION_PAGING, columns: []sadfColumn{
+		{"pgpgin", "pgpgin/s"}, {"pgpgout", "pgpgout/s"}, {"fault", "fault/s"}, {"majflt", "majflt/s"}, {"pgfree", "pgfree/s"},
+		{"pgscank", "pgscank/s"}, {"pgscand", "pgscand/s"}, {"pgsteal", "pgsteal/s"}, {"vmeff-percent", "%vmeff"},
+	}},
+	{path: "io", sectionId: SECTION_IO, columns: []sadfColumn{
+		{"tps", "tps"}, {"io-reads/rtps", "rtps"}, {"io-writes/wtps", "wtps"}, {"io-discard/dtps", "dtps"},
+		{"io-reads/bread", "bread/s"}, {"io-writes/bwrtn", "bwrtn/s"}, {"io-discard/bdscd", "bdscd/s"},
+	}},
+	{path: "memory", sectionId: SECTION_MEM_UTIL, columns: []sadfColumn{
+		{"memfree", "kbmemfree"}, {"avail", "kbavail"}, {"memused", "kbmemused"}, {"memused-percent", "%memused"},
+		{"buffers", "kbbuffers"}, {"cached", "kbcached"}, {"commit", "kbcommit"}, {"commit-percent", "%commit"},
+		{"active", "kbactive"}, {"inactive", "kbinact"}, {"dirty", "kbdirty"},
+	}},
+	{path: "memory", sectionId: SECTION_MEM, columns: []sadfColumn{
+		{"frmpg", "frmpg/s"}, {"bufpg", "bufpg/s"}, {"campg", "campg/s"},
+	}},
+	{path: "memory", sectionId: SECTION_SWAP_SPACE_UTIL, columns: []sadfColumn{
+		{"swpfree", "kbswpfree"}, {"swpused", "kbswpused"}, {"swpused-percent", "%swpused"},
+		{"swpcad", "kbswpcad"}, {"swpcad-percent", "%swpcad"},
+	}},
+	{path: "hugepages", sectionId: SECTION_HUGEPAGES_UTIL, columns: []sadfColumn{
+		{"hugfree", "kbhugfree"}, {"hugused", "kbhugused"}, {"hugused-percent", "%hugused"},
+	}},
+	{path: "kernel", sectionId: SECTION_KERNEL_TABLE_STATUS, columns: []sadfColumn{
+		{"dentunusd", "dentunusd"}, {"file-nr", "file-nr"}, {"inode-nr", "inode-nr"}, {"pty-nr", "pty-nr"},
+	}},
+	{path: "queue", sectionId: SECTION_QLEN_LOADAVG, columns: []sadfColumn{
+		{"runq-sz", "runq-sz"}, {"plist-sz", "plist-sz"}, {"ldavg-1", "ldavg-1"}, {"ldavg-5", "ldavg-5"},
+		{"ldavg-15", "ldavg-15"}, {"blocked", "blocked"},
+	}},
+	{path: "serial", sectionId: SECTION_TTY_DEV, instanceKey: "line", columns: []sadfColumn{
+		{"line", "TTY"}, {"rcvin", "rcvin/s"}, {"xmtin", "xmtin/s"}, {"framerr", "framerr/s"}, {"prtyerr", "prtyerr/s"},
+		{"brk", "brk/s"}, {"ovrun", "ovrun/s"},
+	}},
+	{path: "disk", sectionId: SECTION_BLOCK_DEV, instanceKey: "disk-device", columns: []sadfColumn{
+		{"disk-device", "DEV"}, {"tps", "tps"}, {"rd_sec", "rd_sec/s"}, {"wr_sec", "wr_sec/s"}, {"rkB", "rkB/s"}, {"wkB", "wkB/s"},
+		{"dkB", "dkB/s"}, {"avgrq-sz", "avgrq-sz"}, {"areq-sz", "areq-sz"}, {"avgqu-sz", "avgqu-sz"}, {"aqu-sz", "aqu-sz"},
+		{"await", "await"}, {"svctm", "svctm"}, {"util-percent", "%util"},
+	}},
+	{path: "network/net-dev", sectionId: SECTION_NETWORK_DEV, instanceKey: "iface", columns: []sadfColumn{
+		{"iface", "IFACE"}, {"rxpck", "rxpck/s"}, {"txpck", "txpck/s"}, {"rxkB", "rxkB/s"}, {"txkB", "txkB/s"},
+		{"rxcmp", "rxcmp/s"}, {"txcmp", "txcmp/s"}, {"rxmcst", "rxmcst/s"}, {"ifutil-percent", "%ifutil"},
+	}},
+	{path: "network/net-edev", sectionId: SECTION_NETWORK_EDEV, instanceKey: "iface", columns: []sadfColumn{
+		{"iface", "IFACE"}, {"rxerr", "rxerr/s"}, {"txerr", "txerr/s"}, {"coll", "coll/s"}, {"rxdrop", "rxdrop/s"},
+		{"txdrop", "txdrop/s"}, {"txcarr", "txcarr/s"}, {"rxfram", "rxfram/s"}, {"rxfifo", "rxfifo/s"}, {"txfifo", "txfifo/s"},
+	}},
+	{path: "network/net-nfs", sectionId: SECTION_NETWORK_NFS, columns: []sadfColumn{
+		{"call", "call/s"}, {"retrans", "retrans/s"}, {"read", "read/s"}, {"write", "write/s"}, {"access", "access/s"},
+		{"getatt", "getatt/s"},
+	}},
+	{path: "network/net-nfsd", sectionId: SECTION_NETWORK_NFSD, columns: []sadfColumn{
+		{"scall", "scall/s"}, {"badcall", "badcall/s"}, {"packet", "packet/s"}, {"udp", "udp/s"}, {"tcp", "tcp/s"},
+		{"hit", "hit/s"}, {"miss", "miss/s"}, {"sread", "sread/s"}, {"swrite", "swrite/s"}, {"saccess", "saccess/s"},
+		{"sgetatt", "sgetatt/s"},
+	}},
+	{path: "network/net-sock", sectionId: SECTION_NETWORK_SOCK, columns: []sadfColumn{
+		{"totsck", "totsck"}, {"tcpsck", "tcpsck"}, {"udpsck", "udpsck"}, {"rawsck", "rawsck"}, {"ip-frag", "ip-frag"},
+		{"tcp-tw", "tcp-tw"},
+	}},
+	{path: "network/softnet", sectionId: SECTION_NETWORK_SOFT, instanceKey: "cpu", columns: []sadfColumn{
+		{"cpu", "CPU"}, {"total", "total/s"}, {"dropd", "dropd/s"}, {"squeezd", "squeezd/s"}, {"rx_rcv", "rx_rcv/s"},
+		{"flw_lim", "flw_lim/s"},
+	}},
+	{path: "network/net-ip", sectionId: SECTION_NETWORK_IP, columns: []sadfColumn{
+		{"irec", "irec/s"}, {"fwddgm", "fwddgm/s"}, {"idel", "idel/s"}, {"orq", "orq/s"}, {"asmrq", "asmrq/s"},
+		{"asmok", "asmok/s"}, {"fragok", "fragok/s"}, {"fragcrt", "fragcrt/s"},
+	}},
+	{path: "network/net-eip", sectionId: SECTION_NETWORK_EIP, columns: []sadfColumn{
+		{"ihdrerr", "ihdrerr/s"}, {"iadrerr", "iadrerr/s"}, {"iukwnpr", "iukwnpr/s"}, {"idisc", "idisc/s"},
+		{"odisc", "odisc/s"}, {"onort", "onort/s"}, {"asmf", "asmf/s"}, {"fragf", "fragf/s"},
+	}},
+	{path: "network/net-icmp", sectionId: SECTION_NETWORK_ICMP, columns: []sadfColumn{
+		{"imsg", "imsg/s"}, {"omsg", "omsg/s"}, {"iech", "iech/s"}, {"iechr", "iechr/s"}, {"oech", "oech/s"},
+		{"oechr", "oechr/s"}, {"itm", "itm/s"}, {"itmr", "itmr/s"}, {"otm", "otm/s"}, {"otmr", "otmr/s"},
+		{"iadrmk", "iadrmk/s"}, {"iadrmkr", "iadrmkr/s"}, {"oadrmk", "oadrmk/s"}, {"oadrmkr", "oadrmkr/s"},
+	}},
+	{path: "network/net-eicmp", sectionId: SECTION_NETWORK_EICMP, columns: []sadfColumn{
+		{"ierr", "ierr/s"}, {"oerr", "oerr/s"}, {"idstunr", "idstunr/s"}, {"odstunr", "odstunr/s"}, {"itmex", "itmex/s"},
+		{"otmex", "otmex/s"}, {"iparmpb", "iparmpb/s"}, {"oparmpb", "oparmpb/s"}, {"isrcq", "isrcq/s"}, {"osrcq", "osrcq/s"},
+		{"iredir", "iredir/s"}, {"oredir", "oredir/s"},
+	}},
+	{path: "network/net-tcp", sectionId: SECTION_NETWORK_TCP, columns: []sadfColumn{
+		{"active", "active/s"}, {"passive", "passive/s"}, {"iseg", "iseg/s"}, {"oseg", "oseg/s"},
+	}},
+	{path: "network/net-etcp", sectionId: SECTION_NETWORK_ETCP, columns: []sadfColumn{
+		{"atmptf", "atmptf/s"}, {"estres", "estres/s"}, {"retrans", "retrans/s"}, {"isegerr", "isegerr/s"},
+		{"orsts", "orsts/s"},
+	}},
+	{path: "network/net-udp", sectionId: SECTION_NETWORK_UDP, columns: []sadfColumn{
+		{"idgm", "idgm/s"}, {"odgm", "odgm/s"}, {"noport", "noport/s"}, {"idgmerr", "idgmerr/s"},
+	}},
+	{path: "network/net-sock6", sectionId: SECTION_NETWORK_SOCK6, columns: []sadfColumn{
+		{"tcp6sck", "tcp6sck"}, {"udp6sck", "udp6sck"}, {"raw6sck", "raw6sck"}, {"ip6-frag", "ip6-frag"},
+	}},
+	{path: "network/net-ip6", sectionId: SECTION_NETWORK_IP6, columns: []sadfColumn{
+		{"irec6", "irec6/s"}, {"fwddgm6", "fwddgm6/s"}, {"idel6", "idel6/s"}, {"orq6", "orq6/s"}, {"asmrq6", "asmrq6/s"},
+		{"asmok6", "asmok6/s"}, {"imcpck6", "imcpck6/s"}, {"omcpck6", "omcpck6/s"}, {"fragok6", "fragok6/s"},
+		{"fragcr6", "fragcr6/s"},
+	}},
+	{path: "network/net-eip6", sectionId: SECTION_NETWORK_EIP6, columns: []sadfColumn{
+		{"ihdrer6", "ihdrer6/s"}, {"iadrer6", "iadrer6/s"}, {"iukwnp6", "iukwnp6/s"}, {"i2big6", "i2big6/s"},
+		{"idisc6", "idisc6/s"}, {"odisc6", "odisc6/s"}, {"inort6", "inort6/s"}, {"onort6", "onort6/s"}, {"asmf6", "asmf6/s"},
+		{"fragf6", "fragf6/s"}, {"itrpck6", "itrpck6/s"},
+	}},
+	{path: "network/net-icmp6", sectionId: SECTION_NETWORK_ICMP6, columns: []sadfColumn{
+		{"imsg6", "imsg6/s"}, {"omsg6", "omsg6/s"}, {"iech6", "iech6/s"}, {"iechr6", "iechr6/s"}, {"oechr6", "oechr6/s"},
+		{"igmbq6", "igmbq6/s"}, {"igmbr6", "igmbr6/s"}, {"ogmbr6", "ogmbr6/s"}, {"igmbrd6", "igmbrd6/s"},
+		{"ogmbrd6", "ogmbrd6/s"}, {"irtsol6", "irtsol6/s"}, {"ortsol6", "ortsol6/s"}, {"irtad6", "irtad6/s"},
+		{"inbsol6", "inbsol6/s"}, {"onbsol6", "onbsol6/s"}, {"inbad6", "inbad6/s"}, {"onbad6", "onbad6/s"},
+	}},
+	{path: "network/net-eicmp6", sectionId: SECTION_NETWORK_EICMP6, columns: []sadfColumn{
+		{"ierr6", "ierr6/s"}, {"idtunr6", "idtunr6/s"}, {"odtunr6", "odtunr6/s"}, {"itmex6", "itmex6/s"},
+		{"otmex6", "otmex6/s"}, {"iprmpb6", "iprmpb6/s"}, {"oprmpb6", "oprmpb6/s"}, {"iredir6", "iredir6/s"},
+		{"oredir6", "oredir6/s"}, {"ipck2b6", "ipck2b6/s"}, {"opck2b6", "opck2b6/s"},
+	}},
+	{path: "network/net-udp6", sectionId: SECTION_NETWORK_UDP6, columns: []sadfColumn{
+		{"idgm6", "idgm6/s"}, {"odgm6", "odgm6/s"}, {"noport6", "noport6/s"}, {"idgmer6", "idgmer6/s"},
+	}},
+	{path: "fchosts", sectionId: SECTION_NETWORK_FC, instanceKey: "fchost", columns: []sadfColumn{
+		{"fchost", "FCHOST"}, {"fch_rxf", "fch_rxf/s"}, {"fch_txf", "fch_txf/s"}, {"fch_rxw", "fch_rxw/s"},
+		{"fch_txw", "fch_txw/s"},
+	}},
+	{path: "interrupts", sectionId: SECTION_INTR, instanceKey: "intr", columns: []sadfColumn{
+		{"intr", "INTR"}, {"value", "intr/s"},
+	}},
+	{path: "filesystems", sectionId: SECTION_FILESYSTEM, instanceKey: "filesystem", columns: []sadfColumn{
+		{"filesystem", "FILESYSTEM"}, {"MBfsfree", "MBfsfree"}, {"MBfsused", "MBfsused"}, {"%fsused", "%fsused"},
+		{"%ufsused", "%ufsused"}, {"Ifree", "Ifree"}, {"Iused", "Iused"}, {"%Iused", "%Iused"},
+	}},
+	{path: "psi-cpu", sectionId: SECTION_PSI_CPU, columns: []sadfColumn{
+		{"some_avg10", "%scpu-10"}, {"some_avg60", "%scpu-60"}, {"some_avg300", "%scpu-300"}, {"some_avg", "%scpu"},
+	}},
+	{path: "psi-io", sectionId: SECTION_PSI_IO, columns: []sadfColumn{
+		{"some_avg10", "%sio-10"}, {"some_avg60", "%sio-60"}, {"some_avg300", "%sio-300"}, {"some_avg", "%sio"},
+		{"full_avg10", "%fio-10"}, {"full_avg60", "%fio-60"}, {"full_avg300", "%fio-300"}, {"full_avg", "%fio"},
+	}},
+	{path: "psi-mem", sectionId: SECTION_PSI_MEM, columns: []sadfColumn{
+		{"some_avg10", "%smem-10"}, {"some_avg60", "%smem-60"}, {"some_avg300", "%smem-300"}, {"some_avg", "%smem"},
+		{"full_avg10", "%fmem-10"}, {"full_avg60", "%fmem-60"}, {"full_avg300", "%fmem-300"}, {"full_avg", "%fmem"},
+	}},
+	{path: "power-management/cpu-frequency", sectionId: SECTION_PWR_CPUFREQ, instanceKey: "number", columns: []sadfColumn{
+		{"number", "CPU"}, {"frequency", "MHz"},
+	}},
+	{path: "power-management/fan-speed", sectionId: SECTION_PWR_FAN, instanceKey: "number", columns: []sadfColumn{
+		{"number", "FAN"}, {"rpm", "rpm"}, {"drpm", "drpm"}, {"device", "DEVICE"},
+	}},
+	{path: "power-management/voltage-input", sectionId: SECTION_PWR_IN, instanceKey: "number", columns: []sadfColumn{
+		{"number", "IN"}, {"inV", "inV"}, {"percent-in", "%in"}, {"device", "DEVICE"},
+	}},
+	{path: "power-management/temperature", sectionId: SECTION_PWR_TEMP, instanceKey: "number", columns: []sadfColumn{
+		{"number", "TEMP"}, {"degC", "degC"}, {"percent-temp", "%temp"}, {"device", "DEVICE"},
+	}},
+	{path: "power-management/usb-devices", sectionId: SECTION_PWR_USB, instanceKey: "bus_number", columns: []sadfColumn{
+		{"bus_number", "BUS"}, {"idvendor", "idvendor"}, {"idprod", "idprod"}, {"maxpower", "maxpower"},
+		{"manufact", "manufact"}, {"product", "product"},
+	}},
+}
+
+// lookupJson follows a slash-separated path through nested JSON objects
+func lookupJson(obj interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, "/") {
+		m, ok := obj.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if obj, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return obj, true
+}
+
+func formatJsonValue(val interface{}) string {
+	switch v := val.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func parseSadfJson(r io.Reader, strict bool) (*sarFile, error) {
+	var doc sadfJsonDoc
+	if err := json.NewDecoder(r).Decode(&doc); nil != err {
+		return nil, fmt.Errorf("invalid sadf JSON: %v", err)
+	}
+	if len(doc.Sysstat.Hosts) == 0 {
+		return nil, fmt.Errorf("sadf JSON has no \"hosts\"")
+	}
+
+	host := doc.Sysstat.Hosts[0]
+	f := newSarFile(strict)
+	f.meta = sarMeta{
+		sysname:  host.Sysname,
+		kernel:   host.Release,
+		hostname: host.Nodename,
+		arch:     host.Machine,
+		cpus:     host.Cpus,
+	}
+	if date, err := time.Parse("2006-01-02", host.FileDate); nil == err {
+		f.meta.date = date
+	}
+	// a sarFile is of one host, the others are rejected in strict mode
+	if len(doc.Sysstat.Hosts) > 1 {
+		var dropped []string
+		for _, other := range doc.Sysstat.Hosts[1:] {
+			dropped = append(dropped, other.Nodename)
+		}
+		err := fmt.Errorf("sadf JSON has %d hosts, only %s is read, skipped %s",
+			len(doc.Sysstat.Hosts), host.Nodename, strings.Join(dropped, ", "))
+		if err := f.fail(0, "", err); nil != err {
+			return nil, err
+		}
+	}
+
+	for _, restart := range host.Restarts {
+		if ts, err := time.Parse("2006-01-02 15:04:05", fmt.Sprintf("%s %s", restart.Boot.Date, restart.Boot.Time)); nil == err {
+			f.addRestart(ts)
+		}
+	}
+
+	for _, stat := range host.Statistics {
+		tsObj, _ := stat["timestamp"].(map[string]interface{})
+		date, _ := tsObj["date"].(string)
+		tod, _ := tsObj["time"].(string)
+		ts, err := time.Parse("2006-01-02 15:04:05", fmt.Sprintf("%s %s", date, tod))
+		if nil != err {
+			if err := f.fail(0, "", fmt.Errorf("invalid sadf JSON timestamp: %v", tsObj)); nil != err {
+				return nil, err
+			}
+			continue
+		}
+
+		for _, act := range sadfActivities {
+			obj, found := lookupJson(stat, act.path)
+			if !found {
+				continue
+			}
+			items, isArray := obj.([]interface{})
+			if !isArray {
+				items = []interface{}{obj}
+			}
+			for _, item := range items {
+				var headerSegs, segs []string
+				for _, col := range act.columns {
+					if val, found := lookupJson(item, col.key); found {
+						headerSegs = append(headerSegs, col.name)
+						segs = append(segs, formatJsonValue(val))
+					}
+				}
+				// an activity sharing its object with others only counts when it has more than its instance
+				if len(segs) == 0 || ("" != act.instanceKey && len(segs) == 1) {
+					continue
+				}
+				f.addRecord(act.sectionId, headerSegs, ts, segs)
+			}
+		}
+	}
+
+	return f, nil
+}
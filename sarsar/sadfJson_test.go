@@ -0,0 +1,102 @@
+package sarsar
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+const sadfJson = `{"sysstat": {
+	"hosts": [
+		{
+			"nodename": "db01",
+			"sysname": "Linux",
+			"release": "4.15.0-20-generic",
+			"machine": "x86_64",
+			"number-of-cpus": 2,
+			"file-date": "2018-03-14",
+			"file-utc-time": "00:00:01",
+			"statistics": [
+				{
+					"timestamp": {"date": "2018-03-14", "time": "00:10:01", "utc": 1, "interval": 600},
+					"cpu-load-all": [
+						{"cpu": "all", "usr": 1.50, "nice": 0.00, "sys": 0.50, "iowait": 3.00, "steal": 0.00, "irq": 0.00, "soft": 0.00, "guest": 0.00, "gnice": 0.00, "idle": 95.00},
+						{"cpu": "0", "usr": 2.00, "nice": 0.00, "sys": 1.00, "iowait": 6.00, "steal": 0.00, "irq": 0.00, "soft": 0.00, "guest": 0.00, "gnice": 0.00, "idle": 91.00}
+					],
+					"io": {"tps": 5.00, "io-reads": {"rtps": 1.00, "bread": 8.00}, "io-writes": {"wtps": 4.00, "bwrtn": 80.00}},
+					"memory": {"memfree": 1024, "avail": 2048, "memused": 3072, "memused-percent": 75.00, "buffers": 10, "cached": 20, "commit": 30, "commit-percent": 1.00, "active": 40, "inactive": 50, "dirty": 60},
+					"network": {
+						"net-dev": [
+							{"iface": "eth0", "rxpck": 10.00, "txpck": 20.00, "rxkB": 1.00, "txkB": 2.00, "rxcmp": 0.00, "txcmp": 0.00, "rxmcst": 0.00, "ifutil-percent": 0.00}
+						]
+					}
+				},
+				{
+					"timestamp": {"date": "2018-03-14", "time": "00:20:01", "utc": 1, "interval": 600},
+					"cpu-load-all": [
+						{"cpu": "all", "usr": 2.50, "nice": 0.00, "sys": 0.50, "iowait": 1.00, "steal": 0.00, "irq": 0.00, "soft": 0.00, "guest": 0.00, "gnice": 0.00, "idle": 96.00},
+						{"cpu": "0", "usr": 3.00, "nice": 0.00, "sys": 1.00, "iowait": 2.00, "steal": 0.00, "irq": 0.00, "soft": 0.00, "guest": 0.00, "gnice": 0.00, "idle": 94.00}
+					],
+					"io": {"tps": 6.00, "io-reads": {"rtps": 2.00, "bread": 16.00}, "io-writes": {"wtps": 4.00, "bwrtn": 80.00}},
+					"memory": {"memfree": 512, "avail": 1024, "memused": 3584, "memused-percent": 87.50, "buffers": 10, "cached": 20, "commit": 30, "commit-percent": 1.00, "active": 40, "inactive": 50, "dirty": 60},
+					"network": {
+						"net-dev": [
+							{"iface": "eth0", "rxpck": 30.00, "txpck": 40.00, "rxkB": 3.00, "txkB": 4.00, "rxcmp": 0.00, "txcmp": 0.00, "rxmcst": 0.00, "ifutil-percent": 0.00}
+						]
+					}
+				}
+			]
+		}
+	]
+}}`
+
+func TestParseSadfJson(t *testing.T) {
+	f, err := parseSarInput(strings.NewReader(sadfJson), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "db01 | Linux 4.15.0-20-generic | x86_64 | 2 CPU | 2018-03-14", f.meta.String())
+	assert.Equal(t, 4 /* cpu, io, memory, net-dev */, len(f.sections))
+
+	_, values, err := f.getDataSeriesByName(sectionName(SECTION_CPU_UTIL), "0", "%iowait")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{6, 2}, values)
+
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_IO), NO_INSTANCE, "rtps")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1, 2}, values)
+
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_MEM_UTIL), NO_INSTANCE, "kbmemfree")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1024, 512}, values)
+
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_NETWORK_DEV), "eth0", "txpck/s")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{20, 40}, values)
+
+	times := f.sections[SECTION_CPU_UTIL].instances["all"].times
+	assert.Equal(t, time.Date(2018, 3, 14, 0, 20, 1, 0, time.UTC), times[1])
+}
+
+func TestParseSadfJsonHosts(t *testing.T) {
+	hosts := strings.Replace(sadfJson, `
+	]
+}}`, `,
+		{"nodename": "db02", "statistics": []},
+		{"nodename": "db03", "statistics": []}
+	]
+}}`, 1)
+
+	_, err := parseSarInput(strings.NewReader(hosts), true)
+	assert.EqualError(t, err, "sadf JSON has 3 hosts, only db01 is read, skipped db02, db03")
+
+	f, err := parseSarInput(strings.NewReader(hosts), false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "db01", f.meta.hostname)
+	if assert.Len(t, f.diagnostics, 1) {
+		assert.Equal(t, "sadf JSON has 3 hosts, only db01 is read, skipped db02, db03", f.diagnostics[0].String())
+	}
+}
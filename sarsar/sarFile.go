@@ -3,26 +3,76 @@ package sarsar
 import (
 	"time"
 	"io"
-	"os"
 	"bufio"
 	"strings"
 	"fmt"
 	"strconv"
+	"sort"
+	"math"
 )
 
-type sarRecord struct {
-	time time.Time
-	data map[string]string
+type sarSection struct {
+	instanceColumn string
+	schema         *sarSchema
+	instances      map[string]*sarSeries
+	instanceOrder  []string          // in order of appearance, as sar prints them
+	units          map[string]string // unit of the columns printed with one, by "sar -h"
 }
 
-type sarSection struct {
-	records []*sarRecord
+// sarMeta is what the "Linux ..." file header tells about the captured machine
+type sarMeta struct {
+	sysname  string
+	kernel   string
+	hostname string
+	date     time.Time
+	arch     string
+	cpus     int
+}
+
+func (m sarMeta) String() string {
+	var parts []string
+	if "" != m.hostname {
+		parts = append(parts, m.hostname)
+	}
+	if "" != m.kernel {
+		parts = append(parts, fmt.Sprintf("%s %s", m.sysname, m.kernel))
+	}
+	if "" != m.arch {
+		parts = append(parts, m.arch)
+	}
+	if m.cpus > 0 {
+		parts = append(parts, fmt.Sprintf("%d CPU", m.cpus))
+	}
+	if !m.date.IsZero() {
+		parts = append(parts, m.date.Format("2006-01-02"))
+	}
+	return strings.Join(parts, " | ")
 }
 
 type sarFile struct {
-	sections map[int]*sarSection
+	sections     map[int]*sarSection
+	sectionOrder []int // in order of appearance
+	meta         sarMeta
+	timeLayout   string
+	timeFields   int // 2 for "03:04:05 PM", 1 for 24-hour timestamps
+	lastTimes    map[int]time.Time
+	headers      map[int]string // last header line of each section, to locate its text columns
+	restarts     []time.Time    // "LINUX RESTART" events, in order
+
+	strict      bool // abort on the first problem instead of skipping it
+	diagnostics []sarDiagnostic
+	lineNo      int // line being parsed, 0 for inputs without lines
+	decimalMark rune
+}
+
+// sarBreak is where a series should not be drawn continuously
+type sarBreak struct {
+	index   int // first sample after the break
+	restart bool
+	missing bool // the sample has no value
 }
 
+// built-in sections, each one indexes its definition in sectionRegistry
 const (
 	SECTION_CPU_UTIL                     = iota //= "CPU utilization"
 	SECTION_TASK_CREATION_AND_SYS_SWITCH        //= "task creation and system switching activity"
@@ -43,52 +93,159 @@ const (
 	SECTION_NETWORK_SOFT                        //= "statistics about software-based network processing"
 	SECTION_NETWORK_NFS                         //= "statistics about NFS client activity"
 	SECTION_NETWORK_NFSD                        //= "statistics about NFS server activity"
+	SECTION_NETWORK_IP                          //= "IPv4 network traffic"
+	SECTION_NETWORK_EIP                         //= "IPv4 network errors"
+	SECTION_NETWORK_ICMP                        //= "ICMPv4 network traffic"
+	SECTION_NETWORK_EICMP                       //= "ICMPv4 network errors"
+	SECTION_NETWORK_TCP                         //= "TCPv4 network traffic"
+	SECTION_NETWORK_ETCP                        //= "TCPv4 network errors"
+	SECTION_NETWORK_UDP                         //= "UDPv4 network traffic"
+	SECTION_NETWORK_SOCK6                       //= "sockets (IPv6) in use"
+	SECTION_NETWORK_IP6                         //= "IPv6 network traffic"
+	SECTION_NETWORK_EIP6                        //= "IPv6 network errors"
+	SECTION_NETWORK_ICMP6                       //= "ICMPv6 network traffic"
+	SECTION_NETWORK_EICMP6                      //= "ICMPv6 network errors"
+	SECTION_NETWORK_UDP6                        //= "UDPv6 network traffic"
+	SECTION_NETWORK_FC                          //= "Fibre Channel traffic"
+	SECTION_PWR_CPUFREQ                         //= "CPU clock frequency"
+	SECTION_PWR_FAN                             //= "fans speed"
+	SECTION_PWR_IN                              //= "voltage inputs"
+	SECTION_PWR_TEMP                            //= "devices temperature"
+	SECTION_PWR_USB                             //= "USB devices plugged into the system"
+	SECTION_INTR                                //= "statistics for interrupts"
+	SECTION_FILESYSTEM                          //= "statistics for currently mounted filesystems"
+	SECTION_PSI_CPU                             //= "pressure-stall CPU statistics"
+	SECTION_PSI_IO                              //= "pressure-stall I/O statistics"
+	SECTION_PSI_MEM                             //= "pressure-stall memory statistics"
 	SECTION_END
 )
 
-var section2Name = map[int]string{
-	SECTION_CPU_UTIL:                     "CPU util",
-	SECTION_TASK_CREATION_AND_SYS_SWITCH: "Task Creation & Switch",
-	SECTION_SWAPPING:                     "Swapping statics",
-	SECTION_PAGING:                       "Paging statics",
-	SECTION_IO:                           "IO statics",
-	SECTION_MEM_UTIL:                     "Memory util",
-	SECTION_MEM:                          "Memory statistics",
-	SECTION_SWAP_SPACE_UTIL:              "Swap space util",
-	SECTION_HUGEPAGES_UTIL:               "Hugepages util",
-	SECTION_KERNEL_TABLE_STATUS:          "inode/file/kernel-tables",
-	SECTION_QLEN_LOADAVG:                 "Queue-length & load-avg",
-	SECTION_TTY_DEV:                      "TTY devices activity",
-	SECTION_BLOCK_DEV:                    "Block dev activity",
-	SECTION_NETWORK_DEV:                  "Network statistics",
-	SECTION_NETWORK_EDEV:                 "Network device errors",
-	SECTION_NETWORK_SOCK:                 "Network sockets",
-	SECTION_NETWORK_SOFT:                 "Software-based network processing",
-	SECTION_NETWORK_NFS:                  "NFS client",
-	SECTION_NETWORK_NFSD:                 "NFS server",
-}
+const NO_INSTANCE = ""
+
+// time-of-day layouts sar emits, depending on LC_TIME / S_TIME_FORMAT
+var timeLayouts12h = []string{"03:04:05 PM"}
+var timeLayouts24h = []string{"15:04:05", "15.04.05"}
 
-var name2Section = map[string]int{}
+func (s *sarFile) detectTimeFormat(segs []string) error {
+	if len(segs) >= 2 && ("AM" == strings.ToUpper(segs[1]) || "PM" == strings.ToUpper(segs[1])) {
+		timeStr := strings.ToUpper(fmt.Sprintf("%s %s", segs[0], segs[1]))
+		for _, layout := range timeLayouts12h {
+			if _, err := time.Parse(layout, timeStr); nil == err {
+				s.timeLayout, s.timeFields = layout, 2
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid 12-hour timestamp: %s", timeStr)
+	}
 
-func init() {
-	for k, v := range section2Name {
-		name2Section[v] = k
+	for _, layout := range timeLayouts24h {
+		if _, err := time.Parse(layout, segs[0]); nil == err {
+			s.timeLayout, s.timeFields = layout, 1
+			return nil
+		}
 	}
+	return fmt.Errorf("invalid timestamp: %s", segs[0])
 }
 
 func (s *sarFile) parseSegments(line string) (time.Time, []string, error) {
 	segs := strings.Fields(line)
-	if len(segs) < 3 {
-		return time.Time{}, nil, fmt.Errorf("line header should have 3 segments at least, but line was \"%s\"", line)
+	if 0 == s.timeFields && len(segs) > 0 {
+		if err := s.detectTimeFormat(segs); nil != err {
+			return time.Time{}, nil, err
+		}
+	}
+
+	if len(segs) < s.timeFields+1 {
+		return time.Time{}, nil, fmt.Errorf("line header should have %d segments at least, but line was \"%s\"", s.timeFields+1, line)
 	}
 
-	timeStr := fmt.Sprintf("%s %s", segs[0], segs[1])
-	ts, err := time.Parse("03:04:05 PM", timeStr)
+	timeStr := strings.ToUpper(strings.Join(segs[:s.timeFields], " "))
+	ts, err := time.Parse(s.timeLayout, timeStr)
 	if nil != err {
 		return time.Time{}, nil, fmt.Errorf("invalid timestamp: %s", timeStr)
 	}
 
-	return ts, segs[2:], nil
+	return ts, segs[s.timeFields:], nil
+}
+
+// date layouts sar emits in the file header, depending on LC_TIME / S_TIME_FORMAT
+var dateLayouts = []string{"2006-01-02", "01/02/2006", "01/02/06", "02/01/2006", "02/01/06", "02.01.2006", "2006/01/02"}
+
+// dayFirstLayouts are the day first reading of the month first layouts, tried last
+var dayFirstLayouts = map[string]string{"01/02/2006": "02/01/2006", "01/02/06": "02/01/06"}
+
+// parseFileHeader parses the header line, e.g.
+// "Linux 4.15.0-20-generic (db01) 	03/14/2018 	_x86_64_	(8 CPU)".
+// Older sysstat versions omit the architecture and CPU count.
+func (s *sarFile) parseFileHeader(line string) error {
+	segs := strings.Fields(line)
+	if len(segs) < 4 {
+		return fmt.Errorf("file header should have 4 segments at least, but line was \"%s\"", line)
+	}
+
+	meta := sarMeta{
+		sysname: segs[0],
+		kernel:  segs[1],
+	}
+	for idx := 2; idx < len(segs); idx++ {
+		seg := segs[idx]
+		switch {
+		case "" == meta.hostname && strings.HasPrefix(seg, "(") && strings.HasSuffix(seg, ")"):
+			meta.hostname = strings.Trim(seg, "()")
+		case strings.HasPrefix(seg, "_") && strings.HasSuffix(seg, "_"):
+			meta.arch = strings.Trim(seg, "_")
+		case strings.HasPrefix(seg, "(") && idx+1 < len(segs) && "CPU)" == segs[idx+1]:
+			if cpus, err := strconv.Atoi(strings.TrimPrefix(seg, "(")); nil == err {
+				meta.cpus = cpus
+			}
+			idx++
+		case meta.date.IsZero():
+			meta.date = s.parseHeaderDate(seg, line)
+		}
+	}
+
+	if meta.date.IsZero() {
+		return fmt.Errorf("found no date in file header: \"%v\"", line)
+	}
+	s.meta = meta
+	return nil
+}
+
+// parseHeaderDate reads the date of the file header, zero if seg is none. A day above 12 rules out
+// the month first layouts, a date which reads both ways such as 03/04/2018 is taken month first
+// as sar prints it in the C locale, with a warning.
+func (s *sarFile) parseHeaderDate(seg, line string) time.Time {
+	for _, layout := range dateLayouts {
+		date, err := time.Parse(layout, seg)
+		if nil != err {
+			continue
+		}
+		if dayFirst, found := dayFirstLayouts[layout]; found {
+			if other, err := time.Parse(dayFirst, seg); nil == err && !other.Equal(date) {
+				s.warn(line, fmt.Errorf("ambiguous date %s, read month first as %s rather than %s",
+					seg, date.Format("2006-01-02"), other.Format("2006-01-02")))
+			}
+		}
+		return date
+	}
+	return time.Time{}
+}
+
+// resolveTime attaches the capture date to a time-of-day, rolling the date forward
+// when the timestamps of a section wrap past midnight
+func (s *sarFile) resolveTime(sectionId int, tod time.Time) time.Time {
+	day := s.meta.date
+	last, found := s.lastTimes[sectionId]
+	if found {
+		day = last
+	}
+
+	ts := time.Date(day.Year(), day.Month(), day.Day(), tod.Hour(), tod.Minute(), tod.Second(), 0, time.UTC)
+	if found && ts.Before(last) {
+		ts = ts.AddDate(0, 0, 1)
+	}
+	s.lastTimes[sectionId] = ts
+	return ts
 }
 
 func (s *sarFile) addSection(line string) (int, []string, error) {
@@ -96,144 +253,434 @@ func (s *sarFile) addSection(line string) (int, []string, error) {
 	if nil != err {
 		return 0, nil, err
 	}
-	if len(segs) >= 2 && "CPU" == segs[0] && "%usr" == segs[1] {
-		return SECTION_CPU_UTIL, segs, nil
-	}
-	if len(segs) >= 2 && "proc/s" == segs[0] && "cswch/s" == segs[1] {
-		return SECTION_TASK_CREATION_AND_SYS_SWITCH, segs, nil
-	}
-	if len(segs) >= 2 && "pswpin/s" == segs[0] && "pswpout/s" == segs[1] {
-		return SECTION_SWAPPING, segs, nil
+	sectionId, found := matchSectionHeader(segs)
+	if !found {
+		return 0, nil, fmt.Errorf("unrecognized section header: \"%v\"", line)
 	}
-	if len(segs) >= 2 && "pgpgin/s" == segs[0] && "pgpgout/s" == segs[1] {
-		return SECTION_PAGING, segs, nil
+	s.headers[sectionId] = line
+	return sectionId, segs, nil
+}
+
+// joinTextColumns rebuilds the trailing text columns of a data line which contain spaces,
+// sar writes them left-aligned under their header
+func (s *sarFile) joinTextColumns(sectionId int, headerSegs []string, line string, segs []string) []string {
+	n := sectionRegistry[sectionId].trailingTextColumns(headerSegs)
+	if 0 == n || len(segs) <= len(headerSegs) {
+		return segs
 	}
-	if len(segs) >= 2 && "tps" == segs[0] && "rtps" == segs[1] {
-		return SECTION_IO, segs, nil
+	first := len(headerSegs) - n
+	if 1 == n {
+		return append(segs[:first:first], strings.Join(segs[first:], " "))
 	}
-	if len(segs) >= 2 && "kbmemfree" == segs[0] && ("kbavail" == segs[1] || "kbmemused" == segs[1]) {
-		return SECTION_MEM_UTIL, segs, nil
+
+	header := s.headers[sectionId]
+	offsets := make([]int, n)
+	end := len(header)
+	for i := n - 1; i >= 0; i-- {
+		if end = strings.LastIndex(header[:end], headerSegs[first+i]); end < 0 {
+			return segs
+		}
+		offsets[i] = end
 	}
-	if len(segs) >= 2 && "frmpg/s" == segs[0] && "bufpg/s" == segs[1] {
-		return SECTION_MEM, segs, nil
+	joined := segs[:first:first]
+	for i, start := range offsets {
+		stop := len(line)
+		if i+1 < n && offsets[i+1] < stop {
+			stop = offsets[i+1]
+		}
+		if start > stop {
+			return segs
+		}
+		joined = append(joined, strings.TrimSpace(line[start:stop]))
 	}
-	if len(segs) >= 2 && "kbswpfree" == segs[0] && "kbswpused" == segs[1] {
-		return SECTION_SWAP_SPACE_UTIL, segs, nil
+	return joined
+}
+
+func (s *sarFile) addData(sectionId int, headerSegs []string, line string) error {
+	tod, segs, err := s.parseSegments(line)
+	if nil != err {
+		return err
 	}
-	if len(segs) >= 2 && "kbhugfree" == segs[0] && "kbhugused" == segs[1] {
-		return SECTION_HUGEPAGES_UTIL, segs, nil
+	segs = s.joinTextColumns(sectionId, headerSegs, line, segs)
+
+	if len(segs) != len(headerSegs) {
+		return fmt.Errorf("data line has different segments count with header line: \"%v\"", line)
 	}
-	if len(segs) >= 2 && "dentunusd" == segs[0] && "file-nr" == segs[1] {
-		return SECTION_KERNEL_TABLE_STATUS, segs, nil
+
+	s.addRecord(sectionId, headerSegs, s.resolveTime(sectionId, tod), segs)
+	return nil
+}
+
+// addRecord stores one sample of a section, whatever format it was read from
+func (s *sarFile) addRecord(sectionId int, headerSegs []string, ts time.Time, segs []string) {
+	section := s.getOrAddSection(sectionId)
+	series := section.getOrAddSeries(sectionRegistry[sectionId].instanceOf(headerSegs, segs))
+	row := series.appendRow(ts)
+
+	def := sectionRegistry[sectionId]
+	for idx := 0; DECIMAL_AUTO == s.decimalMark && idx < len(segs); idx++ {
+		if def.isChartable(headerSegs[idx]) {
+			s.decimalMark = detectDecimalMark(segs[idx])
+		}
 	}
-	if len(segs) >= 2 && "runq-sz" == segs[0] && "plist-sz" == segs[1] {
-		return SECTION_QLEN_LOADAVG, segs, nil
+	for idx := range segs {
+		col := section.schema.column(headerSegs[idx], !def.isChartable(headerSegs[idx]))
+		if section.schema.columns[col].text {
+			series.setText(col, row, segs[idx])
+			continue
+		}
+		// values which are no numbers are missing from the charts
+		val, unit, err := s.parseValue(segs[idx])
+		if nil != err {
+			s.warn(segs[idx], fmt.Errorf("column %s: %v", headerSegs[idx], err))
+			continue
+		}
+		series.setValue(col, row, val)
+		if UNIT_NONE != unit {
+			section.units[headerSegs[idx]] = unit
+		}
+		// human-readable sizes are normalized to bytes
+		if decimals := countDecimals(segs[idx]); UNIT_BYTES != unit && decimals > section.schema.columns[col].decimals {
+			section.schema.columns[col].decimals = decimals
+		}
 	}
-	if len(segs) >= 2 && "TTY" == segs[0] && "rcvin/s" == segs[1] {
-		return SECTION_TTY_DEV, segs, nil
+}
+
+func (s *sarFile) getOrAddSection(sectionId int) *sarSection {
+	section, found := s.sections[sectionId]
+	if !found {
+		section = &sarSection{
+			instanceColumn: sectionRegistry[sectionId].InstanceColumn,
+			schema:         newSarSchema(),
+			instances:      map[string]*sarSeries{},
+			units:          map[string]string{},
+		}
+		s.sections[sectionId] = section
+		s.sectionOrder = append(s.sectionOrder, sectionId)
 	}
-	if len(segs) >= 2 && "DEV" == segs[0] && "tps" == segs[1] {
-		return SECTION_BLOCK_DEV, segs, nil
+	return section
+}
+
+func (s *sarSection) getOrAddSeries(instance string) *sarSeries {
+	series, found := s.instances[instance]
+	if !found {
+		series = newSarSeries(s.schema)
+		s.instances[instance] = series
+		s.instanceOrder = append(s.instanceOrder, instance)
 	}
-	if len(segs) >= 2 && "IFACE" == segs[0] && "rxpck/s" == segs[1] {
-		return SECTION_NETWORK_DEV, segs, nil
+	return series
+}
+
+const RESTART_MARKER = "LINUX RESTART"
+
+// GAP_TOLERANCE is how much longer than the sampling interval two samples may be apart
+const GAP_TOLERANCE = 1.5
+
+func (s *sarFile) addRestart(ts time.Time) {
+	// every section lists the same restart
+	for _, restart := range s.restarts {
+		if restart.Equal(ts) {
+			return
+		}
 	}
-	if len(segs) >= 2 && "IFACE" == segs[0] && "rxerr/s" == segs[1] {
-		return SECTION_NETWORK_EDEV, segs, nil
+	s.restarts = append(s.restarts, ts)
+	sort.Slice(s.restarts, func(i, j int) bool {
+		return s.restarts[i].Before(s.restarts[j])
+	})
+}
+
+func (s *sarFile) addRestartLine(sectionId int, line string) error {
+	tod, _, err := s.parseSegments(line)
+	if nil != err {
+		return err
 	}
-	if len(segs) >= 2 && "call/s" == segs[0] && "retrans/s" == segs[1] {
-		return SECTION_NETWORK_NFS, segs, nil
+	s.addRestart(s.resolveTime(sectionId, tod))
+	return nil
+}
+
+// detectInterval is the median distance between samples
+func detectInterval(times []time.Time) time.Duration {
+	var deltas []time.Duration
+	for idx := 1; idx < len(times); idx++ {
+		if delta := times[idx].Sub(times[idx-1]); delta > 0 {
+			deltas = append(deltas, delta)
+		}
 	}
-	if len(segs) >= 2 && "scall/s" == segs[0] && "badcall/s" == segs[1] {
-		return SECTION_NETWORK_NFSD, segs, nil
+	if len(deltas) == 0 {
+		return 0
 	}
-	if len(segs) >= 2 && "totsck" == segs[0] && "tcpsck" == segs[1] {
-		return SECTION_NETWORK_SOCK, segs, nil
+	sort.Slice(deltas, func(i, j int) bool {
+		return deltas[i] < deltas[j]
+	})
+	return deltas[len(deltas)/2]
+}
+
+// getBreaks splits a series at restarts and at gaps larger than the sampling interval
+func (s *sarFile) getBreaks(series *sarSeries) []sarBreak {
+	return s.getTimeBreaks(series.times)
+}
+
+// splitSeries splits every series into segments, once the rows are in order and all the restarts known
+func (s *sarFile) splitSeries() {
+	for _, section := range s.sections {
+		for _, series := range section.instances {
+			series.breaks = s.getBreaks(series)
+		}
 	}
-	if len(segs) >= 2 && "CPU" == segs[0] && "total/s" == segs[1] {
-		return SECTION_NETWORK_SOFT, segs, nil
+}
+
+// getTimeBreaks finds the breaks between sample times
+func (s *sarFile) getTimeBreaks(times []time.Time) []sarBreak {
+	var breaks []sarBreak
+	interval := detectInterval(times)
+	for idx := 1; idx < len(times); idx++ {
+		prev, curr := times[idx-1], times[idx]
+		restart := false
+		for _, ts := range s.restarts {
+			if ts.After(prev) && !ts.After(curr) {
+				restart = true
+				break
+			}
+		}
+		if restart {
+			breaks = append(breaks, sarBreak{index: idx, restart: true})
+		} else if interval > 0 && float64(curr.Sub(prev)) > GAP_TOLERANCE*float64(interval) {
+			breaks = append(breaks, sarBreak{index: idx})
+		}
 	}
+	return breaks
+}
+
+const AVERAGE_PREFIX = "Average:"
+
+// SUMMARY_PREFIX heads the averages of the filesystem section, sar finds "average" misleading there
+const SUMMARY_PREFIX = "Summary:"
 
-	return 0, nil, fmt.Errorf("unrecognized section header: \"%v\"", line)
+func isAverageLine(line string) bool {
+	return strings.HasPrefix(line, AVERAGE_PREFIX) || strings.HasPrefix(line, SUMMARY_PREFIX)
 }
 
-func (s *sarFile) addData(sectionId int, headerSegs []string, line string) error {
-	ts, segs, err := s.parseSegments(line)
-	if nil != err {
-		return err
+func trimAveragePrefix(line string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(line, AVERAGE_PREFIX), SUMMARY_PREFIX)
+}
+
+func (s *sarFile) addAverageSection(line string) (int, []string, error) {
+	segs := strings.Fields(trimAveragePrefix(line))
+	sectionId, found := matchSectionHeader(segs)
+	if !found {
+		return 0, nil, fmt.Errorf("unrecognized section header: \"%v\"", line)
 	}
+	s.headers[sectionId] = line
+	return sectionId, segs, nil
+}
 
+func (s *sarFile) addAverage(sectionId int, headerSegs []string, line string) error {
+	segs := strings.Fields(trimAveragePrefix(line))
+	segs = s.joinTextColumns(sectionId, headerSegs, line, segs)
 	if len(segs) != len(headerSegs) {
-		return fmt.Errorf("data line has different segments count with header line: \"%v\"", line)
+		return fmt.Errorf("average line has different segments count with header line: \"%v\"", line)
 	}
 
 	section, found := s.sections[sectionId]
 	if !found {
-		section = &sarSection{
-			records: []*sarRecord{},
-		}
-		s.sections[sectionId] = section
+		return fmt.Errorf("average line before any data line: \"%v\"", line)
 	}
 
-	record := &sarRecord{
-		time: ts,
-		data: map[string]string{},
+	average := map[string]string{}
+	for idx := range segs {
+		average[headerSegs[idx]] = segs[idx]
 	}
-	section.records = append(section.records, record)
+	section.getOrAddSeries(sectionRegistry[sectionId].instanceOf(headerSegs, segs)).average = average
+	return nil
+}
 
-	for idx := range segs {
-		record.data[headerSegs[idx]] = segs[idx]
+// getAverageByName returns sar's own average of a column
+func (s *sarFile) getAverageByName(sectionName, instance, name string) (float64, bool) {
+	series, err := s.getSeries(sectionName, instance)
+	if nil != err || nil == series.average {
+		return 0, false
+	}
+	valStr, found := series.average[name]
+	if !found {
+		return 0, false
+	}
+	val, _, err := s.parseValue(valStr)
+	if nil != err {
+		return 0, false
 	}
+	return val, true
+}
 
-	return nil
+func (s *sarFile) getDataSeriesByName(sectionName, instance, name string) (labels []string, values []float64, err error) {
+	series, err := s.getSeries(sectionName, instance)
+	if nil != err {
+		return nil, nil, err
+	}
+	col, found := series.schema.index[name]
+	labels = make([]string, series.len())
+	values = make([]float64, series.len())
+	for row, ts := range series.times {
+		labels[row] = ts.Format("Jan 02 15:04:05")
+		// NaN marks a missing value
+		values[row] = math.NaN()
+		if !found {
+			continue
+		}
+		if val, found := series.value(col, row); found {
+			values[row] = val
+		}
+	}
+	return labels, values, nil
 }
 
-func (s *sarFile) getDataSeriesByName(sectionName, name string) (labels []string, values []float64, err error) {
+// getUnit returns the unit a column was printed with, UNIT_NONE if it was printed as plain numbers
+func (s *sarFile) getUnit(sectionName, name string) string {
 	sectionId, err := s.getSectionId(sectionName)
 	if nil != err {
-		return nil, nil, err
+		return UNIT_NONE
 	}
 	section, found := s.sections[sectionId]
 	if !found {
-		return nil, nil, fmt.Errorf("found no section \"%v\" in file", sectionName)
+		return UNIT_NONE
 	}
-	for _, rec := range section.records {
-		val := float64(0)
-		if valStr, found := rec.data[name]; found {
-			if a, err := strconv.ParseFloat(valStr, 64); nil == err {
-				val = a
-			}
-		}
-		labels = append(labels, rec.time.Format("Jan 02 15:04:05"))
-		values = append(values, val)
+	return section.units[name]
+}
+
+func (s *sarFile) getSeries(sectionName, instance string) (*sarSeries, error) {
+	sectionId, err := s.getSectionId(sectionName)
+	if nil != err {
+		return nil, err
 	}
-	return labels, values, nil
+	section, found := s.sections[sectionId]
+	if !found {
+		return nil, fmt.Errorf("found no section \"%v\" in file", sectionName)
+	}
+	series, found := section.instances[instance]
+	if !found {
+		return nil, fmt.Errorf("found no instance \"%v\" in section \"%v\"", instance, sectionName)
+	}
+	return series, nil
 }
 
 func (s *sarFile) getSectionId(sectionName string) (int, error) {
-	sectionId, found := name2Section[sectionName]
+	sectionId, found := getSectionIdByName(sectionName)
 	if !found {
 		return 0, fmt.Errorf("found no section named \"%v\"", sectionName)
 	}
 	return sectionId, nil
 }
 
-func parseSarFile(path string) (*sarFile, error) {
-	f, err := os.Open(path)
+func newSarFile(strict bool) *sarFile {
+	return &sarFile{
+		sections:    map[int]*sarSection{},
+		lastTimes:   map[int]time.Time{},
+		headers:     map[int]string{},
+		strict:      strict,
+		decimalMark: forcedDecimalMark,
+	}
+}
+
+// warn records a problem which does not stop parsing, not even in strict mode
+func (s *sarFile) warn(text string, err error) {
+	s.diagnostics = append(s.diagnostics, sarDiagnostic{
+		lineNo: s.lineNo,
+		text:   text,
+		err:    err,
+	})
+}
+
+// sarDiagnostic is a problem skipped over when parsing in lenient mode
+type sarDiagnostic struct {
+	path   string // input file, set when several were merged
+	lineNo int    // 0 if the input has no lines
+	text   string
+	err    error
+}
+
+func (d sarDiagnostic) String() string {
+	prefix := ""
+	if "" != d.path {
+		prefix = d.path + ": "
+	}
+	if 0 == d.lineNo {
+		return prefix + d.err.Error()
+	}
+	// most errors quote the line already
+	if strings.Contains(d.err.Error(), d.text) {
+		return fmt.Sprintf("%sline %d: %v", prefix, d.lineNo, d.err)
+	}
+	return fmt.Sprintf("%sline %d: %v: \"%s\"", prefix, d.lineNo, d.err, d.text)
+}
+
+// fail reports a parsing problem, which aborts parsing in strict mode
+// and is recorded as a diagnostic otherwise
+func (s *sarFile) fail(lineNo int, text string, err error) error {
+	if s.strict {
+		if 0 == lineNo {
+			return err
+		}
+		return fmt.Errorf("line %d: %v", lineNo, err)
+	}
+	s.diagnostics = append(s.diagnostics, sarDiagnostic{
+		lineNo: lineNo,
+		text:   text,
+		err:    err,
+	})
+	return nil
+}
+
+// parseSarFile parses one input file, compressed or not
+func parseSarFile(path string, strict bool) (*sarFile, error) {
+	in, err := openSarInput(path)
 	if nil != err {
 		return nil, err
 	}
-	defer f.Close()
+	f, err := parseSarInput(in, strict)
+	// a corrupt archive shows when the decompressor exits
+	if closeErr := in.Close(); nil == err && nil != closeErr {
+		return nil, fmt.Errorf("%s: %v", path, closeErr)
+	}
+	return f, err
+}
 
-	sarFile := &sarFile{
-		sections: map[int]*sarSection{},
+// parseSarInput loads an input of any format, its series split into segments
+func parseSarInput(r io.Reader, strict bool) (*sarFile, error) {
+	f, err := readSarInput(r, strict)
+	if nil != err {
+		return nil, err
 	}
+	f.splitSeries()
+	return f, nil
+}
 
-	buf := bufio.NewReader(f)
+// readSarInput picks the loader by peeking at the beginning of the input
+func readSarInput(r io.Reader, strict bool) (*sarFile, error) {
+	buf := bufio.NewReader(r)
+	if magic, err := buf.Peek(2); nil == err && isSadcMagic(magic) {
+		return parseSadcReader(buf, strict)
+	}
+	head, _ := buf.Peek(512)
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(string(head)), "{"):
+		return parseSadfJson(buf, strict)
+	case isSadfDb(string(head)):
+		return parseSadfDb(buf, strict)
+	case isSadfPpc(string(head)):
+		return parseSadfPpc(buf, strict)
+	}
+	return parseSarReader(buf, strict)
+}
+
+func parseSarReader(r io.Reader, strict bool) (*sarFile, error) {
+	sarFile := newSarFile(strict)
+
+	buf := bufio.NewReader(r)
 	sectionBegins := false
+	skipSection := false
 	lastSection := 0
 	var lastSectionHeaderSegs []string
-	for {
+	for lineNo := 1; ; lineNo++ {
+		sarFile.lineNo = lineNo
 		bs, _, err := buf.ReadLine()
 		if nil != err {
 			if io.EOF == err {
@@ -245,11 +692,11 @@ func parseSarFile(path string) (*sarFile, error) {
 
 		//file begin
 		if strings.HasPrefix(line, "Linux ") {
-			continue
-		}
-
-		//ignore averages
-		if strings.HasPrefix(line, "Average: ") {
+			if err := sarFile.parseFileHeader(line); nil != err {
+				if err := sarFile.fail(lineNo, line, err); nil != err {
+					return nil, err
+				}
+			}
 			continue
 		}
 
@@ -258,14 +705,27 @@ func parseSarFile(path string) (*sarFile, error) {
 			continue
 		}
 
-		if sectionBegins {
+		//averages, headed by their own header line in multi-instance sections
+		if isAverageLine(line) {
+			if sectionBegins {
+				sectionBegins = false
+				lastSection, lastSectionHeaderSegs, err = sarFile.addAverageSection(line)
+				skipSection = nil != err
+			} else if !skipSection {
+				err = sarFile.addAverage(lastSection, lastSectionHeaderSegs, line)
+			}
+		} else if strings.Contains(line, RESTART_MARKER) {
+			err = sarFile.addRestartLine(lastSection, line)
+		} else if sectionBegins {
 			sectionBegins = false
 			lastSection, lastSectionHeaderSegs, err = sarFile.addSection(line)
-			if nil != err {
-				return nil, err
-			}
-		} else {
-			if err := sarFile.addData(lastSection, lastSectionHeaderSegs, line); nil != err {
+			// data lines of an unrecognized section are skipped silently in lenient mode
+			skipSection = nil != err
+		} else if !skipSection {
+			err = sarFile.addData(lastSection, lastSectionHeaderSegs, line)
+		}
+		if nil != err {
+			if err := sarFile.fail(lineNo, line, err); nil != err {
 				return nil, err
 			}
 		}
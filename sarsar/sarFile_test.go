@@ -3,14 +3,625 @@ package sarsar
 import (
 	"testing"
 	"github.com/stretchr/testify/assert"
+	"strings"
+	"time"
+	"math"
 )
 
 func TestParseSarFile(t *testing.T) {
-	f, err := parseSarFile("../test/sa14.out")
-	assert.NoError(t, err)
+	f, err := parseSarFile("../test/sa14.out", true)
+	if !assert.NoError(t, err) {
+		return
+	}
 	assert.Equal(t, 18 /* sections */, len(f.sections))
 	cpuUtil := f.sections[SECTION_CPU_UTIL]
-	assert.Equal(t, 8060 /* rows */, len(cpuUtil.records))
-	record := cpuUtil.records[0]
-	assert.Equal(t, 11 /* columns */, len(record.data))
+	assert.Equal(t, "CPU", cpuUtil.instanceColumn)
+	rows := 0
+	for _, series := range cpuUtil.instances {
+		rows += series.len()
+	}
+	assert.Equal(t, 8060 /* rows */, rows)
+	assert.Equal(t, 11 /* columns */, len(cpuUtil.schema.columns))
+}
+
+const sarCpuAll = `Linux 4.15.0-20-generic (db01) 	03/14/2018 	_x86_64_	(2 CPU)
+
+12:00:01 AM     CPU     %usr    %nice     %sys  %iowait    %steal      %irq     %soft    %guest    %gnice     %idle
+12:10:01 AM     all      1.50      0.00      0.50      3.00      0.00      0.00      0.00      0.00      0.00     95.00
+12:10:01 AM       0      2.00      0.00      1.00      6.00      0.00      0.00      0.00      0.00      0.00     91.00
+12:10:01 AM       1      1.00      0.00      0.00      0.00      0.00      0.00      0.00      0.00      0.00     99.00
+
+12:10:01 AM     CPU     %usr    %nice     %sys  %iowait    %steal      %irq     %soft    %guest    %gnice     %idle
+12:20:01 AM     all      2.50      0.00      0.50      1.00      0.00      0.00      0.00      0.00      0.00     96.00
+12:20:01 AM       0      3.00      0.00      1.00      2.00      0.00      0.00      0.00      0.00      0.00     94.00
+12:20:01 AM       1      2.00      0.00      0.00      0.00      0.00      0.00      0.00      0.00      0.00     98.00
+
+12:00:01 AM       DEV       tps  rd_sec/s  wr_sec/s  avgrq-sz  avgqu-sz     await     svctm     %util
+12:10:01 AM    dev8-0      5.00      0.00     80.00     16.00      0.01      2.00      1.00      0.50
+12:10:01 AM  dev253-0      4.00      0.00     64.00     16.00      0.02      5.00      1.00      0.40
+12:20:01 AM    dev8-0      6.00      0.00     96.00     16.00      0.01      2.50      1.00      0.60
+12:20:01 AM  dev253-0      3.00      0.00     48.00     16.00      0.02      7.00      1.00      0.30
+`
+
+func TestParseSarFileInstances(t *testing.T) {
+	f, err := parseSarReader(strings.NewReader(sarCpuAll), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	cpuUtil := f.sections[SECTION_CPU_UTIL]
+	assert.Equal(t, "CPU", cpuUtil.instanceColumn)
+	assert.Equal(t, 3 /* all, 0, 1 */, len(cpuUtil.instances))
+	_, values, err := f.getDataSeriesByName(sectionName(SECTION_CPU_UTIL), "0", "%iowait")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{6, 2}, values)
+
+	blockDev := f.sections[SECTION_BLOCK_DEV]
+	assert.Equal(t, "DEV", blockDev.instanceColumn)
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_BLOCK_DEV), "dev253-0", "await")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{5, 7}, values)
+
+	_, _, err = f.getDataSeriesByName(sectionName(SECTION_BLOCK_DEV), "dev8-16", "await")
+	assert.Error(t, err)
+}
+
+const sar24h = `Linux 4.15.0-20-generic (db01) 	2018-03-14 	_x86_64_	(2 CPU)
+
+13:00:01        CPU     %usr    %nice     %sys  %iowait    %steal      %irq     %soft    %guest    %gnice     %idle
+13:10:01        all      1.50      0.00      0.50      3.00      0.00      0.00      0.00      0.00      0.00     95.00
+13:20:01        all      2.50      0.00      0.50      1.00      0.00      0.00      0.00      0.00      0.00     96.00
+
+13:00:01      runq-sz  plist-sz   ldavg-1   ldavg-5  ldavg-15   blocked
+13:10:01            1       300      0.50      0.40      0.30         0
+13:20:01            2       310      0.60      0.45      0.32         1
+`
+
+func TestParseSarFile24h(t *testing.T) {
+	f, err := parseSarReader(strings.NewReader(sar24h), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 1, f.timeFields)
+
+	labels, values, err := f.getDataSeriesByName(sectionName(SECTION_CPU_UTIL), "all", "%usr")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1.5, 2.5}, values)
+	assert.Equal(t, "Mar 14 13:10:01", labels[0])
+
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_QLEN_LOADAVG), NO_INSTANCE, "plist-sz")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{300, 310}, values)
+}
+
+func TestParseSarFile12h(t *testing.T) {
+	f, err := parseSarReader(strings.NewReader(sarCpuAll), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 2, f.timeFields)
+	assert.Equal(t, 11 /* columns */, len(f.sections[SECTION_CPU_UTIL].schema.columns))
+}
+
+const sarMidnight = `Linux 4.15.0-20-generic (db01) 	03/14/2018 	_x86_64_	(2 CPU)
+
+11:40:01 PM     CPU     %usr    %nice     %sys  %iowait    %steal      %irq     %soft    %guest    %gnice     %idle
+11:50:01 PM     all      1.00      0.00      0.50      3.00      0.00      0.00      0.00      0.00      0.00     95.50
+12:00:01 AM     all      2.00      0.00      0.50      1.00      0.00      0.00      0.00      0.00      0.00     96.50
+12:10:01 AM     all      3.00      0.00      0.50      1.00      0.00      0.00      0.00      0.00      0.00     95.50
+
+11:40:01 PM   pswpin/s pswpout/s
+11:50:01 PM      0.00      0.00
+12:00:01 AM      1.00      2.00
+`
+
+func TestParseSarFileMidnightRollover(t *testing.T) {
+	f, err := parseSarReader(strings.NewReader(sarMidnight), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, time.Date(2018, 3, 14, 0, 0, 0, 0, time.UTC), f.meta.date)
+
+	times := f.sections[SECTION_CPU_UTIL].instances["all"].times
+	assert.Equal(t, time.Date(2018, 3, 14, 23, 50, 1, 0, time.UTC), times[0])
+	assert.Equal(t, time.Date(2018, 3, 15, 0, 0, 1, 0, time.UTC), times[1])
+	assert.Equal(t, time.Date(2018, 3, 15, 0, 10, 1, 0, time.UTC), times[2])
+
+	// every section starts again from the header date
+	times = f.sections[SECTION_SWAPPING].instances[NO_INSTANCE].times
+	assert.Equal(t, time.Date(2018, 3, 14, 23, 50, 1, 0, time.UTC), times[0])
+	assert.Equal(t, time.Date(2018, 3, 15, 0, 0, 1, 0, time.UTC), times[1])
+}
+
+func TestParseFileHeaderDate(t *testing.T) {
+	for header, date := range map[string]time.Time{
+		"Linux 4.15.0 (db01) 	03/14/2018 	_x86_64_	(2 CPU)": time.Date(2018, 3, 14, 0, 0, 0, 0, time.UTC),
+		"Linux 4.15.0 (db01) 	2018-03-14 	_x86_64_	(2 CPU)": time.Date(2018, 3, 14, 0, 0, 0, 0, time.UTC),
+		"Linux 2.6.32 (db01) 	03/14/18 	_x86_64_	(2 CPU)":   time.Date(2018, 3, 14, 0, 0, 0, 0, time.UTC),
+		"Linux 4.15.0 (db01) 	14/03/2018 	_x86_64_	(2 CPU)": time.Date(2018, 3, 14, 0, 0, 0, 0, time.UTC),
+		"Linux 4.15.0 (db01) 	14.03.2018 	_x86_64_	(2 CPU)": time.Date(2018, 3, 14, 0, 0, 0, 0, time.UTC),
+	} {
+		f := &sarFile{}
+		assert.NoError(t, f.parseFileHeader(header), header)
+		assert.Equal(t, date, f.meta.date, header)
+		assert.Empty(t, f.diagnostics, header)
+	}
+
+	// reads both ways
+	f := &sarFile{}
+	assert.NoError(t, f.parseFileHeader("Linux 4.15.0 (db01) 	03/04/2018 	_x86_64_	(2 CPU)"))
+	assert.Equal(t, time.Date(2018, 3, 4, 0, 0, 0, 0, time.UTC), f.meta.date)
+	if assert.Len(t, f.diagnostics, 1) {
+		assert.Contains(t, f.diagnostics[0].String(), "ambiguous date 03/04/2018")
+	}
+	f = &sarFile{}
+	assert.NoError(t, f.parseFileHeader("Linux 4.15.0 (db01) 	04/04/2018 	_x86_64_	(2 CPU)"))
+	assert.Empty(t, f.diagnostics)
+}
+
+func TestParseFileHeaderMeta(t *testing.T) {
+	f := &sarFile{}
+	assert.NoError(t, f.parseFileHeader("Linux 4.15.0-20-generic (db01.example.com) 	03/14/2018 	_x86_64_	(8 CPU)"))
+	assert.Equal(t, sarMeta{
+		sysname:  "Linux",
+		kernel:   "4.15.0-20-generic",
+		hostname: "db01.example.com",
+		date:     time.Date(2018, 3, 14, 0, 0, 0, 0, time.UTC),
+		arch:     "x86_64",
+		cpus:     8,
+	}, f.meta)
+	assert.Equal(t, "db01.example.com | Linux 4.15.0-20-generic | x86_64 | 8 CPU | 2018-03-14", f.meta.String())
+
+	f = &sarFile{}
+	assert.NoError(t, f.parseFileHeader("Linux 2.6.18-194.el5 (web3) 	03/14/18"))
+	assert.Equal(t, "web3", f.meta.hostname)
+	assert.Equal(t, "", f.meta.arch)
+	assert.Equal(t, 0, f.meta.cpus)
+}
+
+const sarAverages = `Linux 4.15.0-20-generic (db01) 	03/14/2018 	_x86_64_	(2 CPU)
+
+12:00:01 AM     CPU     %usr    %nice     %sys  %iowait    %steal      %irq     %soft    %guest    %gnice     %idle
+12:10:01 AM     all      1.50      0.00      0.50      3.00      0.00      0.00      0.00      0.00      0.00     95.00
+12:10:01 AM       0      2.00      0.00      1.00      6.00      0.00      0.00      0.00      0.00      0.00     91.00
+
+12:10:01 AM     CPU     %usr    %nice     %sys  %iowait    %steal      %irq     %soft    %guest    %gnice     %idle
+12:20:01 AM     all      2.50      0.00      0.50      1.00      0.00      0.00      0.00      0.00      0.00     96.00
+12:20:01 AM       0      3.00      0.00      1.00      2.00      0.00      0.00      0.00      0.00      0.00     94.00
+
+Average:        CPU     %usr    %nice     %sys  %iowait    %steal      %irq     %soft    %guest    %gnice     %idle
+Average:        all      2.00      0.00      0.50      2.00      0.00      0.00      0.00      0.00      0.00     95.50
+Average:          0      2.50      0.00      1.00      4.00      0.00      0.00      0.00      0.00      0.00     92.50
+
+12:00:01 AM    proc/s   cswch/s
+12:10:01 AM      0.50    100.00
+12:20:01 AM      0.60    110.00
+Average:         0.55    105.00
+`
+
+func TestParseSarFileAverages(t *testing.T) {
+	f, err := parseSarReader(strings.NewReader(sarAverages), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	avg, found := f.getAverageByName(sectionName(SECTION_CPU_UTIL), "0", "%iowait")
+	assert.True(t, found)
+	assert.Equal(t, float64(4), avg)
+	assert.Equal(t, 2, f.sections[SECTION_CPU_UTIL].instances["0"].len())
+
+	avg, found = f.getAverageByName(sectionName(SECTION_TASK_CREATION_AND_SYS_SWITCH), NO_INSTANCE, "cswch/s")
+	assert.True(t, found)
+	assert.Equal(t, float64(105), avg)
+
+	_, found = f.getAverageByName(sectionName(SECTION_TASK_CREATION_AND_SYS_SWITCH), NO_INSTANCE, "nope/s")
+	assert.False(t, found)
+}
+
+const sarRestart = `Linux 4.15.0-20-generic (db01) 	03/14/2018 	_x86_64_	(2 CPU)
+
+10:00:01 AM    proc/s   cswch/s
+10:10:01 AM      0.50    100.00
+10:20:01 AM      0.60    110.00
+10:30:01 AM      0.60    110.00
+
+11:05:01 AM       LINUX RESTART	(2 CPU)
+
+11:10:01 AM    proc/s   cswch/s
+11:20:01 AM      0.70    120.00
+11:30:01 AM      0.80    130.00
+01:30:01 PM      0.80    130.00
+Average:         0.65    116.67
+
+10:00:01 AM   pswpin/s pswpout/s
+10:10:01 AM      0.00      0.00
+10:20:01 AM      0.00      0.00
+10:30:01 AM      0.00      0.00
+
+11:05:01 AM       LINUX RESTART	(2 CPU)
+
+11:10:01 AM   pswpin/s pswpout/s
+11:20:01 AM      1.00      2.00
+Average:         0.25      0.50
+`
+
+func TestParseSarFileRestarts(t *testing.T) {
+	f, err := parseSarReader(strings.NewReader(sarRestart), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []time.Time{time.Date(2018, 3, 14, 11, 5, 1, 0, time.UTC)}, f.restarts)
+
+	series, err := f.getSeries(sectionName(SECTION_TASK_CREATION_AND_SYS_SWITCH), NO_INSTANCE)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, series.len())
+	assert.Equal(t, 10*time.Minute, detectInterval(series.times))
+	assert.Equal(t, []sarBreak{{index: 3, restart: true}, {index: 5}}, f.getBreaks(series))
+
+	series, err = f.getSeries(sectionName(SECTION_SWAPPING), NO_INSTANCE)
+	assert.NoError(t, err)
+	assert.Equal(t, []sarBreak{{index: 3, restart: true}}, f.getBreaks(series))
+}
+
+func TestSarSeriesSegments(t *testing.T) {
+	f, err := parseSarInput(strings.NewReader(sarRestart), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	series, err := f.getSeries(sectionName(SECTION_TASK_CREATION_AND_SYS_SWITCH), NO_INSTANCE)
+	assert.NoError(t, err)
+	assert.Equal(t, []sarBreak{{index: 3, restart: true}, {index: 5}}, series.breaks)
+	assert.Equal(t, [][2]int{{0, 3}, {3, 5}, {5, 6}}, series.segments())
+	b, found := series.breakBefore(3)
+	assert.True(t, found)
+	assert.True(t, b.restart)
+	_, found = series.breakBefore(4)
+	assert.False(t, found)
+
+	// the overlay keeps the breaks of every series
+	data, err := f.makeChartData([]chartKey{
+		{section: sectionName(SECTION_SWAPPING), instance: NO_INSTANCE, col: "pswpin/s"},
+		{section: sectionName(SECTION_TASK_CREATION_AND_SYS_SWITCH), instance: NO_INSTANCE, col: "cswch/s"},
+	}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []sarBreak{{index: 3, restart: true}, {index: 5}}, data.breaks)
+
+	assert.Equal(t, [][2]int{{0, 0}}, (&sarSeries{}).segments())
+}
+
+const sarTruncated = `Linux 4.15.0-20-generic (db01) 	03/14/2018 	_x86_64_	(2 CPU)
+
+10:00:01 AM    proc/s   cswch/s
+10:10:01 AM      0.50    100.00
+10:20:01 AM      0.60
+10:30:01 AM      0.70    120.00
+
+10:00:01 AM   unknown1  unknown2
+10:10:01 AM      1.00      2.00
+10:20:01 AM      3.00      4.00
+
+10:00:01 AM   pswpin/s pswpout/s
+10:10:01 AM      0.00      0.00
+10:20:01 AM      0.0
+`
+
+func TestParseSarFileLenient(t *testing.T) {
+	_, err := parseSarReader(strings.NewReader(sarTruncated), true)
+	assert.EqualError(t, err, "line 5: data line has different segments count with header line: \"10:20:01 AM      0.60\"")
+
+	f, err := parseSarReader(strings.NewReader(sarTruncated), false)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var lineNos []int
+	for _, diag := range f.diagnostics {
+		lineNos = append(lineNos, diag.lineNo)
+	}
+	assert.Equal(t, []int{5, 8, 14}, lineNos)
+	assert.Equal(t, "10:00:01 AM   unknown1  unknown2", f.diagnostics[1].text)
+
+	series, err := f.getSeries(sectionName(SECTION_TASK_CREATION_AND_SYS_SWITCH), NO_INSTANCE)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, series.len())
+
+	series, err = f.getSeries(sectionName(SECTION_SWAPPING), NO_INSTANCE)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, series.len())
+}
+
+const sarPower = `Linux 4.15.0-20-generic (db01) 	03/14/2018 	_x86_64_	(2 CPU)
+
+10:00:01 AM     CPU       MHz
+10:10:01 AM     all   1600.00
+10:10:01 AM       0   1200.00
+10:10:01 AM       1   2000.00
+
+10:00:01 AM    TEMP      degC     %temp                 DEVICE
+10:10:01 AM       1     40.00     40.00      coretemp-isa-0000
+10:10:01 AM       2     85.00     85.00      coretemp-isa-0000
+
+10:00:01 AM     BUS  idvendor    idprod  maxpower manufact                product
+10:10:01 AM       1      1d6b         2         0 Linux 4.15.0 ehci_hcd   EHCI Host Controller
+10:10:01 AM       2       46d      c52b        98 Logitech                USB Receiver
+
+Average:        CPU       MHz
+Average:        all   1600.00
+Average:          0   1200.00
+Average:          1   2000.00
+
+Average:       TEMP      degC     %temp                 DEVICE
+Average:          1     40.00     40.00      coretemp-isa-0000
+Average:          2     85.00     85.00      coretemp-isa-0000
+
+Average:        BUS  idvendor    idprod  maxpower manufact                product
+Average:          1      1d6b         2         0 Linux 4.15.0 ehci_hcd   EHCI Host Controller
+Average:          2       46d      c52b        98 Logitech                USB Receiver
+`
+
+func TestParseSarFilePowerManagement(t *testing.T) {
+	f, err := parseSarReader(strings.NewReader(sarPower), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, values, err := f.getDataSeriesByName(sectionName(SECTION_PWR_CPUFREQ), "1", "MHz")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{2000}, values)
+
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_PWR_TEMP), "2 (coretemp-isa-0000)", "degC")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{85}, values)
+
+	series, err := f.getSeries(sectionName(SECTION_PWR_USB), "1 (1d6b 2 EHCI Host Controller)")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "Linux 4.15.0 ehci_hcd", series.format(series.schema.index["manufact"], 0))
+	assert.Equal(t, "Linux 4.15.0 ehci_hcd", series.average["manufact"])
+
+	avg, found := f.getAverageByName(sectionName(SECTION_PWR_USB), "2 (46d c52b USB Receiver)", "maxpower")
+	assert.True(t, found)
+	assert.Equal(t, float64(98), avg)
+}
+
+const sarNetworkProtocols = `Linux 4.15.0-20-generic (db01) 	03/14/2018 	_x86_64_	(2 CPU)
+
+10:00:01 AM  active/s passive/s    iseg/s    oseg/s
+10:10:01 AM      1.50      3.00    120.00    118.00
+10:20:01 AM      2.50      4.00    130.00    128.00
+
+10:00:01 AM  atmptf/s  estres/s retrans/s isegerr/s   orsts/s
+10:10:01 AM      0.00      0.10      0.50      0.00      0.20
+10:20:01 AM      0.00      0.10      7.25      0.00      0.20
+
+10:00:01 AM   tcp6sck   udp6sck   raw6sck  ip6-frag
+10:10:01 AM         4         2         1         0
+10:20:01 AM         4         2         1         0
+
+10:00:01 AM    FCHOST fch_rxf/s fch_txf/s fch_rxw/s fch_txw/s
+10:10:01 AM     host0     10.00     20.00    100.00    200.00
+10:20:01 AM     host0     11.00     21.00    110.00    210.00
+`
+
+func TestParseSarFileNetworkProtocols(t *testing.T) {
+	f, err := parseSarReader(strings.NewReader(sarNetworkProtocols), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, values, err := f.getDataSeriesByName(sectionName(SECTION_NETWORK_ETCP), NO_INSTANCE, "retrans/s")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0.5, 7.25}, values)
+
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_NETWORK_TCP), NO_INSTANCE, "passive/s")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{3, 4}, values)
+
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_NETWORK_SOCK6), NO_INSTANCE, "tcp6sck")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{4, 4}, values)
+
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_NETWORK_FC), "host0", "fch_txw/s")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{200, 210}, values)
+}
+
+const sarIntrFsPsi = `Linux 5.15.0-20-generic (db01) 	03/14/2018 	_x86_64_	(2 CPU)
+
+10:00:01 AM      INTR    intr/s
+10:10:01 AM       sum    950.00
+10:10:01 AM         0     12.00
+10:20:01 AM       sum    990.00
+10:20:01 AM         0     14.00
+
+10:00:01 AM  MBfsfree  MBfsused   %fsused  %ufsused     Ifree     Iused    %Iused FILESYSTEM
+10:10:01 AM     10000      5000     33.33     36.00    600000     40000      6.25 /dev/sda1
+10:10:01 AM       200       300     60.00     60.00      1000        24      2.34 /mnt/my disk
+10:20:01 AM     10000      5100     33.78     36.40    600000     40000      6.25 /dev/sda1
+10:20:01 AM       200       300     60.00     60.00      1000        24      2.34 /mnt/my disk
+
+10:00:01 AM  %scpu-10  %scpu-60 %scpu-300     %scpu
+10:10:01 AM      1.20      0.80      0.50      0.90
+10:20:01 AM     12.50      4.10      1.30      9.80
+
+10:00:01 AM   %sio-10   %sio-60  %sio-300     %sio   %fio-10   %fio-60  %fio-300     %fio
+10:10:01 AM      0.00      0.00      0.00      0.00      0.00      0.00      0.00      0.00
+10:20:01 AM      3.00      1.00      0.30      2.50      2.00      0.60      0.20      1.80
+
+Average:         INTR    intr/s
+Average:          sum    970.00
+Average:            0     13.00
+
+Summary:     MBfsfree  MBfsused   %fsused  %ufsused     Ifree     Iused    %Iused FILESYSTEM
+Summary:        10000      5050     33.55     36.20    600000     40000      6.25 /dev/sda1
+Summary:          200       300     60.00     60.00      1000        24      2.34 /mnt/my disk
+`
+
+func TestParseSarFileIntrFilesystemPsi(t *testing.T) {
+	f, err := parseSarReader(strings.NewReader(sarIntrFsPsi), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, values, err := f.getDataSeriesByName(sectionName(SECTION_INTR), "0", "intr/s")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{12, 14}, values)
+
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_FILESYSTEM), "/dev/sda1", "MBfsused")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{5000, 5100}, values)
+
+	avg, found := f.getAverageByName(sectionName(SECTION_FILESYSTEM), "/mnt/my disk", "%fsused")
+	assert.True(t, found)
+	assert.Equal(t, float64(60), avg)
+
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_PSI_CPU), NO_INSTANCE, "%scpu-10")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1.2, 12.5}, values)
+
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_PSI_IO), NO_INSTANCE, "%fio")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0, 1.8}, values)
+}
+
+const sarHuman = `Linux 4.15.0-20-generic (db01) 	03/14/2018 	_x86_64_	(2 CPU)
+
+10:00:01 AM kbmemfree   kbavail kbmemused  %memused kbbuffers  kbcached
+10:10:01 AM      1.2G      2.5G      5.8G     82.9%    128.0M      1.0G
+10:20:01 AM    512.0M      1.9G      6.5G     92.7%    128.0M      1.0G
+10:30:01 AM    500.0M      1.9G      oops     92.9%    128.0M      1.0G
+`
+
+func TestParseSarFileHumanReadable(t *testing.T) {
+	f, err := parseSarReader(strings.NewReader(sarHuman), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	name := sectionName(SECTION_MEM_UTIL)
+	_, values, err := f.getDataSeriesByName(name, NO_INSTANCE, "kbmemfree")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1.2 * 1024 * 1024 * 1024, 512 * 1024 * 1024, 500 * 1024 * 1024}, values)
+	assert.Equal(t, UNIT_BYTES, f.getUnit(name, "kbmemfree"))
+
+	_, values, err = f.getDataSeriesByName(name, NO_INSTANCE, "%memused")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{82.9, 92.7, 92.9}, values)
+	assert.Equal(t, UNIT_PERCENT, f.getUnit(name, "%memused"))
+
+	if assert.Equal(t, 1, len(f.diagnostics)) {
+		assert.Equal(t, "line 6: column kbmemused: unparsable value \"oops\"", f.diagnostics[0].String())
+	}
+}
+
+const sarPretty = `Linux 4.15.0-20-generic (db01) 	03/14/2018 	_x86_64_	(2 CPU)
+
+10:00:01 AM       tps     rkB/s     wkB/s     dkB/s   areq-sz    aqu-sz     await     %util DEV
+10:10:01 AM     12.00     48.0k      1.5M      0.0k     132.0k      0.02      1.50      2.40 sda
+10:10:01 AM      1.00      0.0k      4.0k      0.0k       4.0k      0.00      0.20      0.10 nvme0n1
+10:20:01 AM     10.00     40.0k      1.0M      0.0k     104.0k      0.01      1.20      2.00 sda
+10:20:01 AM      2.00      0.0k      8.0k      0.0k       4.0k      0.00      0.30      0.20 nvme0n1
+
+10:00:01 AM   rxpck/s   txpck/s    rxkB/s    txkB/s   rxcmp/s   txcmp/s  rxmcst/s   %ifutil IFACE
+10:10:01 AM    100.00     50.00    128.0k     64.0k      0.00      0.00      0.00      0.10 eth0
+10:20:01 AM    200.00     80.00    256.0k     96.0k      0.00      0.00      0.00      0.21 eth0
+Average:       150.00     65.00    192.0k     80.0k      0.00      0.00      0.00      0.16 eth0
+`
+
+func TestParseSarFilePretty(t *testing.T) {
+	f, err := parseSarReader(strings.NewReader(sarPretty), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"sda", "nvme0n1"}, f.sections[SECTION_BLOCK_DEV].instanceOrder)
+	name := sectionName(SECTION_BLOCK_DEV)
+	_, values, err := f.getDataSeriesByName(name, "nvme0n1", "tps")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1, 2}, values)
+	_, values, err = f.getDataSeriesByName(name, "sda", "wkB/s")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1.5 * 1024 * 1024, 1024 * 1024}, values)
+
+	name = sectionName(SECTION_NETWORK_DEV)
+	_, values, err = f.getDataSeriesByName(name, "eth0", "rxpck/s")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{100, 200}, values)
+	avg, found := f.getAverageByName(name, "eth0", "txpck/s")
+	assert.True(t, found)
+	assert.Equal(t, float64(65), avg)
+	assert.Empty(t, f.diagnostics)
+}
+
+const sarDecimalComma = `Linux 4.15.0-20-generic (db01) 	14.03.2018 	_x86_64_	(2 CPU)
+
+10:00:01        CPU     %user     %nice   %system   %iowait    %steal     %idle
+10:10:01        all      1,50      0,00      0,50      3,25      0,00     94,75
+10:20:01        all      2,00      0,00      1,00         -      0,00     97,00
+
+10:00:01     proc/s   cswch/s
+10:10:01       0,50    100,00
+10:20:01       0,60    110,00
+Average:       0,55    105,00
+`
+
+func TestParseSarFileDecimalComma(t *testing.T) {
+	f, err := parseSarReader(strings.NewReader(sarDecimalComma), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, DECIMAL_COMMA, f.decimalMark)
+
+	_, values, err := f.getDataSeriesByName(sectionName(SECTION_CPU_UTIL), "all", "%user")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{1.5, 2}, values)
+
+	// missing, not 0
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_CPU_UTIL), "all", "%iowait")
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(values)) {
+		assert.Equal(t, 3.25, values[0])
+		assert.True(t, math.IsNaN(values[1]))
+	}
+	assert.Equal(t, 1, len(f.diagnostics))
+
+	avg, found := f.getAverageByName(sectionName(SECTION_TASK_CREATION_AND_SYS_SWITCH), NO_INSTANCE, "cswch/s")
+	assert.True(t, found)
+	assert.Equal(t, float64(105), avg)
+
+	SetDecimalMark(DECIMAL_POINT)
+	defer SetDecimalMark(DECIMAL_AUTO)
+	f, err = parseSarReader(strings.NewReader(sarDecimalComma), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, values, err = f.getDataSeriesByName(sectionName(SECTION_CPU_UTIL), "all", "%user")
+	assert.NoError(t, err)
+	assert.True(t, math.IsNaN(values[0]))
+}
+
+func TestParseSarFileOrder(t *testing.T) {
+	f, err := parseSarReader(strings.NewReader(sarCpuAll), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []int{SECTION_CPU_UTIL, SECTION_BLOCK_DEV}, f.sectionOrder)
+	assert.Equal(t, []string{"all", "0", "1"}, f.sections[SECTION_CPU_UTIL].instanceOrder)
+	assert.Equal(t, []string{"dev8-0", "dev253-0"}, f.sections[SECTION_BLOCK_DEV].instanceOrder)
+
+	var cols []string
+	for _, col := range f.sections[SECTION_BLOCK_DEV].schema.columns {
+		cols = append(cols, col.name)
+	}
+	assert.Equal(t, strings.Fields("DEV tps rd_sec/s wr_sec/s avgrq-sz avgqu-sz await svctm %util"), cols)
+
+	// rows print back in header order
+	series := f.sections[SECTION_BLOCK_DEV].instances["dev253-0"]
+	var row []string
+	for col := range series.schema.columns {
+		row = append(row, series.format(col, 1))
+	}
+	assert.Equal(t, strings.Fields("dev253-0 3.00 0.00 48.00 16.00 0.02 7.00 1.00 0.30"), row)
 }
@@ -0,0 +1,213 @@
+package sarsar
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// compressed inputs are recognized by their magic bytes, whatever their names
+var (
+	GZIP_MAGIC = []byte{0x1f, 0x8b}
+	XZ_MAGIC   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	ZSTD_MAGIC = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// STDIN_INPUT names the standard input among the input files, the UI reads the keyboard from the terminal anyway
+const STDIN_INPUT = "-"
+
+// sarInput is an input file, decompressed if needed
+type sarInput struct {
+	io.Reader
+	closers []func() error
+}
+
+func (in *sarInput) Close() error {
+	var firstErr error
+	for idx := len(in.closers) - 1; idx >= 0; idx-- {
+		if err := in.closers[idx](); nil != err && nil == firstErr {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func openSarInput(path string) (*sarInput, error) {
+	var err error
+	var f io.ReadCloser = ioutil.NopCloser(os.Stdin)
+	if STDIN_INPUT != path {
+		if f, err = os.Open(path); nil != err {
+			return nil, err
+		}
+	}
+	in := &sarInput{closers: []func() error{f.Close}}
+
+	buf := bufio.NewReader(f)
+	magic, _ := buf.Peek(len(XZ_MAGIC))
+	switch {
+	case bytes.HasPrefix(magic, GZIP_MAGIC):
+		gz, err := gzip.NewReader(buf)
+		if nil != err {
+			in.Close()
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		in.Reader = gz
+		in.closers = append(in.closers, gz.Close)
+	case bytes.HasPrefix(magic, XZ_MAGIC):
+		in.Reader, err = xz.NewReader(buf)
+	case bytes.HasPrefix(magic, ZSTD_MAGIC):
+		var zr *zstd.Decoder
+		// a single goroutine is enough to keep up with the parser
+		if zr, err = zstd.NewReader(buf, zstd.WithDecoderConcurrency(1)); nil == err {
+			in.Reader = zr
+			in.closers = append(in.closers, func() error {
+				zr.Close()
+				return nil
+			})
+		}
+	default:
+		in.Reader = buf
+	}
+	if nil != err {
+		in.Close()
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return in, nil
+}
+
+// expandInputs turns the input arguments into file paths: globs are expanded
+// and directories stand for the files they contain
+func expandInputs(args []string) ([]string, error) {
+	var paths []string
+	stdin := false
+	for _, arg := range args {
+		if STDIN_INPUT == arg {
+			if stdin {
+				return nil, fmt.Errorf("the standard input can be read only once")
+			}
+			stdin = true
+			paths = append(paths, arg)
+			continue
+		}
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(arg); nil != err {
+				return nil, fmt.Errorf("invalid pattern %s: %v", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no file matches %s", arg)
+			}
+		}
+		for _, match := range matches {
+			stat, err := os.Stat(match)
+			if nil != err {
+				return nil, err
+			}
+			if !stat.IsDir() {
+				paths = append(paths, match)
+				continue
+			}
+			entries, err := ioutil.ReadDir(match)
+			if nil != err {
+				return nil, err
+			}
+			for _, entry := range entries {
+				if entry.Mode().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+					paths = append(paths, filepath.Join(match, entry.Name()))
+				}
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no input file")
+	}
+	return paths, nil
+}
+
+// parseSarFiles parses every input and merges them into one chronological file
+func parseSarFiles(args []string, strict bool) (*sarFile, error) {
+	paths, err := expandInputs(args)
+	if nil != err {
+		return nil, err
+	}
+	if len(paths) == 1 {
+		return parseSarFile(paths[0], strict)
+	}
+
+	var files []*sarFile
+	for _, path := range paths {
+		f, err := parseSarFile(path, strict)
+		if nil != err {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		for idx := range f.diagnostics {
+			f.diagnostics[idx].path = path
+		}
+		files = append(files, f)
+	}
+	return mergeSarFiles(files, strict), nil
+}
+
+// mergeSarFiles puts the samples of several files together in time order, a sample
+// of a time already seen (overlapping captures) is dropped. sar's averages cover
+// one file each and are left out.
+func mergeSarFiles(files []*sarFile, strict bool) *sarFile {
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].firstTime().Before(files[j].firstTime())
+	})
+
+	merged := newSarFile(strict)
+	for _, f := range files {
+		if "" == merged.meta.hostname {
+			merged.meta = f.meta
+		}
+		for _, ts := range f.restarts {
+			merged.addRestart(ts)
+		}
+		merged.diagnostics = append(merged.diagnostics, f.diagnostics...)
+
+		for _, sectionId := range f.sectionOrder {
+			src := f.sections[sectionId]
+			dst := merged.getOrAddSection(sectionId)
+			for col, unit := range src.units {
+				dst.units[col] = unit
+			}
+			for _, instance := range src.instanceOrder {
+				dst.getOrAddSeries(instance).appendSeries(src.instances[instance])
+			}
+		}
+	}
+
+	for _, section := range merged.sections {
+		for _, series := range section.instances {
+			series.sortRows()
+		}
+	}
+	// a file may end where the next one starts, or with a restart
+	merged.splitSeries()
+	return merged
+}
+
+// firstTime is the time of the earliest sample of the file
+func (s *sarFile) firstTime() time.Time {
+	var first time.Time
+	for _, section := range s.sections {
+		for _, series := range section.instances {
+			if series.len() > 0 && (first.IsZero() || series.times[0].Before(first)) {
+				first = series.times[0]
+			}
+		}
+	}
+	return first
+}
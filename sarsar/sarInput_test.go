@@ -0,0 +1,172 @@
+package sarsar
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/ulikunitz/xz"
+)
+
+// overlaps sar24h at 13:20:01
+const sar24hLater = `Linux 4.15.0-20-generic (db01) 	2018-03-14 	_x86_64_	(2 CPU)
+
+13:10:01        CPU     %usr    %nice     %sys  %iowait    %steal      %irq     %soft    %guest    %gnice     %idle
+13:20:01        all      9.50      0.00      0.50      1.00      0.00      0.00      0.00      0.00      0.00     89.00
+13:30:01        all      3.50      0.00      0.50      1.00      0.00      0.00      0.00      0.00      0.00     95.00
+13:40:01        all      bogus     0.00      0.50      1.00      0.00      0.00      0.00      0.00      0.00     95.00
+`
+
+const sarNextDay = `Linux 4.15.0-20-generic (db01) 	2018-03-15 	_x86_64_	(2 CPU)
+
+00:00:01        CPU     %usr    %nice     %sys  %iowait    %steal      %irq     %soft    %guest    %gnice     %idle
+00:10:01        all      4.50      0.00      0.50      1.00      0.00      0.00      0.00      0.00      0.00     94.00
+`
+
+func writeInput(t *testing.T, dir, name string, content []byte) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, content, 0644); nil != err {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseSarFileCompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sarsar")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(sar24h))
+	w.Close()
+	inputs := []string{writeInput(t, dir, "sa14", gz.Bytes())}
+
+	var xzBuf bytes.Buffer
+	xw, err := xz.NewWriter(&xzBuf)
+	if !assert.NoError(t, err) {
+		return
+	}
+	xw.Write([]byte(sar24h))
+	xw.Close()
+	inputs = append(inputs, writeInput(t, dir, "sa14.xz", xzBuf.Bytes()))
+
+	var zstdBuf bytes.Buffer
+	zw, err := zstd.NewWriter(&zstdBuf)
+	if !assert.NoError(t, err) {
+		return
+	}
+	zw.Write([]byte(sar24h))
+	zw.Close()
+	inputs = append(inputs, writeInput(t, dir, "sa14.zst", zstdBuf.Bytes()))
+
+	for _, input := range inputs {
+		f, err := parseSarFile(input, true)
+		if !assert.NoError(t, err, input) {
+			continue
+		}
+		_, values, err := f.getDataSeriesByName(sectionName(SECTION_CPU_UTIL), "all", "%usr")
+		assert.NoError(t, err)
+		assert.Equal(t, []float64{1.5, 2.5}, values, input)
+	}
+
+	// the magic bytes decide, not the name
+	corrupt := writeInput(t, dir, "corrupt", append([]byte{}, gz.Bytes()[:20]...))
+	_, err = parseSarFile(corrupt, false)
+	assert.Error(t, err)
+}
+
+func TestParseSarFilesMerge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sarsar")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	writeInput(t, dir, "sa15", []byte(sarNextDay))
+	writeInput(t, dir, "sa14b", []byte(sar24hLater))
+	writeInput(t, dir, "sa14", []byte(sar24h))
+
+	for _, args := range [][]string{
+		{dir},
+		{filepath.Join(dir, "sa1*")},
+		{filepath.Join(dir, "sa15"), filepath.Join(dir, "sa14b"), filepath.Join(dir, "sa14")},
+	} {
+		f, err := parseSarFiles(args, false)
+		if !assert.NoError(t, err, args) {
+			continue
+		}
+		series, err := f.getSeries(sectionName(SECTION_CPU_UTIL), "all")
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.Equal(t, []time.Time{
+			time.Date(2018, 3, 14, 13, 10, 1, 0, time.UTC),
+			time.Date(2018, 3, 14, 13, 20, 1, 0, time.UTC),
+			time.Date(2018, 3, 14, 13, 30, 1, 0, time.UTC),
+			time.Date(2018, 3, 14, 13, 40, 1, 0, time.UTC),
+			time.Date(2018, 3, 15, 0, 10, 1, 0, time.UTC),
+		}, series.times)
+
+		// the overlapping 13:20:01 sample is taken from the earlier file
+		_, values, _ := f.getDataSeriesByName(sectionName(SECTION_CPU_UTIL), "all", "%usr")
+		assert.Equal(t, 2.5, values[1])
+		assert.True(t, math.IsNaN(values[3]))
+		assert.Equal(t, 4.5, values[4])
+
+		// the runq section is only in one file
+		_, values, err = f.getDataSeriesByName(sectionName(SECTION_QLEN_LOADAVG), NO_INSTANCE, "runq-sz")
+		assert.NoError(t, err)
+		assert.Equal(t, []float64{1, 2}, values)
+
+		if assert.Equal(t, 1, len(f.diagnostics)) {
+			assert.True(t, strings.HasPrefix(f.diagnostics[0].String(), filepath.Join(dir, "sa14b")+": line 6: "))
+		}
+	}
+
+	_, err = parseSarFiles([]string{filepath.Join(dir, "nothing*")}, false)
+	assert.Error(t, err)
+}
+
+func TestParseSarFilesStdin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sarsar")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(sar24hLater))
+	w.Close()
+	stdin, err := os.Open(writeInput(t, dir, "stdin", gz.Bytes()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer stdin.Close()
+	saved := os.Stdin
+	defer func() {
+		os.Stdin = saved
+	}()
+	os.Stdin = stdin
+
+	f, err := parseSarFiles([]string{STDIN_INPUT, writeInput(t, dir, "sa14", []byte(sar24h))}, false)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, values, _ := f.getDataSeriesByName(sectionName(SECTION_CPU_UTIL), "all", "%usr")
+	assert.Equal(t, 4, len(values))
+	assert.Equal(t, 3.5, values[2])
+
+	_, err = parseSarFiles([]string{STDIN_INPUT, STDIN_INPUT}, false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,256 @@
+package sarsar
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// sarColumn describes one column of a section
+type sarColumn struct {
+	name     string
+	text     bool // not a number, e.g. the instance or a device name
+	decimals int  // most decimals any value was written with, to print them back alike
+}
+
+// sarSchema is the column layout shared by all the series of a section, in header order
+type sarSchema struct {
+	columns []*sarColumn
+	index   map[string]int
+}
+
+func newSarSchema() *sarSchema {
+	return &sarSchema{
+		index: map[string]int{},
+	}
+}
+
+// column returns the index of a column, adding it when a header brings a new one
+func (s *sarSchema) column(name string, text bool) int {
+	if idx, found := s.index[name]; found {
+		return idx
+	}
+	s.columns = append(s.columns, &sarColumn{name: name, text: text})
+	s.index[name] = len(s.columns) - 1
+	return len(s.columns) - 1
+}
+
+// sarBitmap holds one bit per row
+type sarBitmap []uint64
+
+func (b *sarBitmap) set(row int, on bool) {
+	for len(*b) <= row/64 {
+		*b = append(*b, 0)
+	}
+	if on {
+		(*b)[row/64] |= 1 << uint(row%64)
+	} else {
+		(*b)[row/64] &^= 1 << uint(row%64)
+	}
+}
+
+func (b sarBitmap) get(row int) bool {
+	if row/64 >= len(b) {
+		return false
+	}
+	return b[row/64]&(1<<uint(row%64)) != 0
+}
+
+// sarSeries stores the samples of one instance column by column
+type sarSeries struct {
+	schema  *sarSchema
+	times   []time.Time
+	values  [][]float64       // numeric columns by schema index, nil for text columns
+	missing []sarBitmap       // set for the rows without a number
+	texts   [][]string        // text columns by schema index, nil for numeric columns
+	average map[string]string // sar's own "Average:" line, if any, as printed
+	breaks  []sarBreak        // split the rows into segments, at restarts and sampling gaps
+}
+
+func newSarSeries(schema *sarSchema) *sarSeries {
+	return &sarSeries{
+		schema: schema,
+	}
+}
+
+func (s *sarSeries) len() int {
+	return len(s.times)
+}
+
+// grow makes room for the columns the schema got since the last row, their earlier rows are missing
+func (s *sarSeries) grow() {
+	for idx := len(s.values); idx < len(s.schema.columns); idx++ {
+		if s.schema.columns[idx].text {
+			s.values = append(s.values, nil)
+			s.missing = append(s.missing, nil)
+			s.texts = append(s.texts, make([]string, len(s.times)))
+			continue
+		}
+		missing := sarBitmap{}
+		for row := range s.times {
+			missing.set(row, true)
+		}
+		s.values = append(s.values, make([]float64, len(s.times)))
+		s.missing = append(s.missing, missing)
+		s.texts = append(s.texts, nil)
+	}
+}
+
+// appendRow adds a row whose values are all missing until set
+func (s *sarSeries) appendRow(ts time.Time) int {
+	s.grow()
+	row := len(s.times)
+	s.times = append(s.times, ts)
+	for idx := range s.schema.columns {
+		if s.schema.columns[idx].text {
+			s.texts[idx] = append(s.texts[idx], "")
+			continue
+		}
+		s.values[idx] = append(s.values[idx], 0)
+		s.missing[idx].set(row, true)
+	}
+	return row
+}
+
+func (s *sarSeries) setValue(col, row int, val float64) {
+	s.grow()
+	s.values[col][row] = val
+	s.missing[col].set(row, false)
+}
+
+func (s *sarSeries) setText(col, row int, text string) {
+	s.grow()
+	// the same device names repeat on every row, share them instead of keeping every line alive
+	if row > 0 && s.texts[col][row-1] == text {
+		text = s.texts[col][row-1]
+	} else {
+		text = string([]byte(text))
+	}
+	s.texts[col][row] = text
+}
+
+// segments returns the rows [lo, hi) of each run of samples between breaks
+func (s *sarSeries) segments() [][2]int {
+	var segments [][2]int
+	lo := 0
+	for _, b := range s.breaks {
+		segments = append(segments, [2]int{lo, b.index})
+		lo = b.index
+	}
+	if lo < len(s.times) || 0 == len(segments) {
+		segments = append(segments, [2]int{lo, len(s.times)})
+	}
+	return segments
+}
+
+// breakBefore returns the break between a row and the previous one, false if the row continues it
+func (s *sarSeries) breakBefore(row int) (sarBreak, bool) {
+	idx := sort.Search(len(s.breaks), func(i int) bool {
+		return s.breaks[i].index >= row
+	})
+	if idx < len(s.breaks) && s.breaks[idx].index == row {
+		return s.breaks[idx], true
+	}
+	return sarBreak{}, false
+}
+
+// value returns a number of the series, false if it's missing or the column holds text
+func (s *sarSeries) value(col, row int) (float64, bool) {
+	if col >= len(s.values) || nil == s.values[col] || s.missing[col].get(row) {
+		return 0, false
+	}
+	return s.values[col][row], true
+}
+
+// format prints a value back the way sar did, "-" if it's missing
+func (s *sarSeries) format(col, row int) string {
+	if col < len(s.texts) && nil != s.texts[col] {
+		return s.texts[col][row]
+	}
+	val, found := s.value(col, row)
+	if !found {
+		return "-"
+	}
+	return strconv.FormatFloat(val, 'f', s.schema.columns[col].decimals, 64)
+}
+
+// countDecimals tells how many decimals a value was written with
+func countDecimals(s string) int {
+	for idx := len(s) - 1; idx >= 0; idx-- {
+		if '.' == s[idx] || ',' == s[idx] {
+			n := 0
+			for _, c := range []byte(s[idx+1:]) {
+				if isDigit(c) {
+					n++
+				}
+			}
+			return n
+		}
+	}
+	return 0
+}
+
+// appendSeries adds the rows of a series of another file, matching columns by name
+func (s *sarSeries) appendSeries(other *sarSeries) {
+	cols := make([]int, len(other.schema.columns))
+	for idx, column := range other.schema.columns {
+		cols[idx] = s.schema.column(column.name, column.text)
+		if column.decimals > s.schema.columns[cols[idx]].decimals {
+			s.schema.columns[cols[idx]].decimals = column.decimals
+		}
+	}
+	for row, ts := range other.times {
+		dstRow := s.appendRow(ts)
+		for idx, col := range cols {
+			if other.schema.columns[idx].text {
+				if idx < len(other.texts) {
+					s.setText(col, dstRow, other.texts[idx][row])
+				}
+			} else if val, found := other.value(idx, row); found {
+				s.setValue(col, dstRow, val)
+			}
+		}
+	}
+}
+
+// sortRows puts the rows in time order, keeping only the first row of a time seen several times
+func (s *sarSeries) sortRows() {
+	s.grow()
+	order := make([]int, len(s.times))
+	for row := range order {
+		order[row] = row
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return s.times[order[i]].Before(s.times[order[j]])
+	})
+
+	var times []time.Time
+	var kept []int
+	for _, row := range order {
+		if len(times) > 0 && times[len(times)-1].Equal(s.times[row]) {
+			continue
+		}
+		times = append(times, s.times[row])
+		kept = append(kept, row)
+	}
+
+	for col := range s.schema.columns {
+		if nil != s.texts[col] {
+			texts := make([]string, len(kept))
+			for row, from := range kept {
+				texts[row] = s.texts[col][from]
+			}
+			s.texts[col] = texts
+			continue
+		}
+		values := make([]float64, len(kept))
+		missing := sarBitmap{}
+		for row, from := range kept {
+			values[row] = s.values[col][from]
+			missing.set(row, s.missing[col].get(from))
+		}
+		s.values[col] = values
+		s.missing[col] = missing
+	}
+	s.times = times
+}
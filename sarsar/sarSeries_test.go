@@ -0,0 +1,53 @@
+package sarsar
+
+import (
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSarBitmap(t *testing.T) {
+	var b sarBitmap
+	b.set(3, true)
+	b.set(64, true)
+	b.set(130, true)
+	b.set(64, false)
+	assert.True(t, b.get(3))
+	assert.False(t, b.get(64))
+	assert.True(t, b.get(130))
+	assert.False(t, b.get(1000))
+}
+
+func TestSarSeriesGrowingSchema(t *testing.T) {
+	schema := newSarSchema()
+	series := newSarSeries(schema)
+	ts := time.Date(2018, 3, 14, 10, 0, 0, 0, time.UTC)
+
+	row := series.appendRow(ts)
+	series.setValue(schema.column("tps", false), row, 12.5)
+	schema.columns[schema.index["tps"]].decimals = 2
+
+	// a later header brings a new column, which is missing before
+	row = series.appendRow(ts.Add(time.Minute))
+	series.setValue(schema.column("tps", false), row, 13)
+	series.setValue(schema.column("dtps", false), row, 1)
+	series.setText(schema.column("DEV", true), row, "sda")
+
+	assert.Equal(t, 2, series.len())
+	_, found := series.value(schema.index["dtps"], 0)
+	assert.False(t, found)
+	val, found := series.value(schema.index["dtps"], 1)
+	assert.True(t, found)
+	assert.Equal(t, float64(1), val)
+
+	assert.Equal(t, "12.50", series.format(schema.index["tps"], 0))
+	assert.Equal(t, "-", series.format(schema.index["dtps"], 0))
+	assert.Equal(t, "sda", series.format(schema.index["DEV"], 1))
+}
+
+func TestCountDecimals(t *testing.T) {
+	assert.Equal(t, 2, countDecimals("12.50"))
+	assert.Equal(t, 2, countDecimals("12,50"))
+	assert.Equal(t, 1, countDecimals("37.5%"))
+	assert.Equal(t, 0, countDecimals("1200"))
+}
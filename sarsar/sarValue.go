@@ -0,0 +1,83 @@
+package sarsar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// units of the values parsed by parseSarValue
+const (
+	UNIT_NONE    = ""
+	UNIT_BYTES   = "B"
+	UNIT_PERCENT = "%"
+)
+
+// decimal marks, locales like de_DE make sar write "12,34"
+const (
+	DECIMAL_AUTO  = 0 // detected per file
+	DECIMAL_POINT = '.'
+	DECIMAL_COMMA = ','
+)
+
+// forcedDecimalMark overrides the detection of the decimal mark in every file
+var forcedDecimalMark rune = DECIMAL_AUTO
+
+// SetDecimalMark forces the decimal mark of the input, DECIMAL_AUTO detects it per file
+func SetDecimalMark(mark rune) {
+	forcedDecimalMark = mark
+}
+
+// detectDecimalMark finds the decimal mark of a value like "12,34", DECIMAL_AUTO if the value has none
+func detectDecimalMark(s string) rune {
+	for idx := 1; idx+1 < len(s); idx++ {
+		if ('.' == s[idx] || ',' == s[idx]) && isDigit(s[idx-1]) && isDigit(s[idx+1]) {
+			return rune(s[idx])
+		}
+	}
+	return DECIMAL_AUTO
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// parseValue parses a value of the file with its decimal mark
+func (s *sarFile) parseValue(str string) (float64, string, error) {
+	if DECIMAL_COMMA == s.decimalMark {
+		str = strings.Replace(str, ",", ".", 1)
+	}
+	return parseSarValue(str)
+}
+
+// human-readable size suffixes of "sar -h", each one 1024 times the previous
+const SIZE_SUFFIXES = "BKMGTPE"
+
+// parseSarValue parses a value as printed by sar, including the human-readable
+// forms of "sar -h" ("1.2G", "512.0M", "37.5%"). Sizes are normalized to bytes.
+func parseSarValue(s string) (float64, string, error) {
+	s = strings.TrimSpace(s)
+	if val, err := strconv.ParseFloat(s, 64); nil == err {
+		return val, UNIT_NONE, nil
+	}
+	if "" == s {
+		return 0, UNIT_NONE, fmt.Errorf("empty value")
+	}
+
+	num, suffix := s[:len(s)-1], s[len(s)-1:]
+	val, err := strconv.ParseFloat(num, 64)
+	if nil != err {
+		return 0, UNIT_NONE, fmt.Errorf("unparsable value \"%s\"", s)
+	}
+	if UNIT_PERCENT == suffix {
+		return val, UNIT_PERCENT, nil
+	}
+	// sysstat writes "k" in lower case
+	if exp := strings.Index(SIZE_SUFFIXES, strings.ToUpper(suffix)); exp >= 0 {
+		for ; exp > 0; exp-- {
+			val *= 1024
+		}
+		return val, UNIT_BYTES, nil
+	}
+	return 0, UNIT_NONE, fmt.Errorf("unparsable value \"%s\", unknown unit \"%s\"", s, suffix)
+}
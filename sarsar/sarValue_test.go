@@ -0,0 +1,35 @@
+package sarsar
+
+import (
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSarValue(t *testing.T) {
+	cases := []struct {
+		s    string
+		val  float64
+		unit string
+	}{
+		{"12.50", 12.5, UNIT_NONE},
+		{"-3.00", -3, UNIT_NONE},
+		{"37.5%", 37.5, UNIT_PERCENT},
+		{"0.0B", 0, UNIT_BYTES},
+		{"38.0k", 38 * 1024, UNIT_BYTES},
+		{"512.0M", 512 * 1024 * 1024, UNIT_BYTES},
+		{"1.5G", 1.5 * 1024 * 1024 * 1024, UNIT_BYTES},
+		{"2.0T", 2 * 1024 * 1024 * 1024 * 1024, UNIT_BYTES},
+	}
+	for _, c := range cases {
+		val, unit, err := parseSarValue(c.s)
+		if assert.NoError(t, err, c.s) {
+			assert.Equal(t, c.val, val, c.s)
+			assert.Equal(t, c.unit, unit, c.s)
+		}
+	}
+
+	for _, s := range []string{"", "abc", "1.2X", "%"} {
+		_, _, err := parseSarValue(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -4,14 +4,55 @@ import (
 	"github.com/jroimartin/gocui"
 	"github.com/miguelmota/cointop/pkg/table"
 	"fmt"
+	"errors"
 	"github.com/ikarishinjieva/sarsar/sarsar/ui"
+	"io"
+	"math"
+	"strings"
+	"time"
 )
 
 var file *sarFile
 
-func SarSar(inputFile string) error {
+// chartStatus describes the charted series in the status bar
+var chartStatus string
+
+// showDiagnostics toggles the diagnostics panel over the chart
+var showDiagnostics bool
+
+// chartKeys are the charted columns, the marked ones if any
+var chartKeys []chartKey
+
+// markedKeys are the columns marked in the menu to be overlaid, in marking order
+var markedKeys []chartKey
+
+// tableKey is the column picked last in the menu, the table lists its series
+var tableKey chartKey
+
+// dualAxis scales the overlaid series but the first on a right Y axis
+var dualAxis bool
+
+// window is the zoomed part of the chart and the table
+var window chartWindow
+
+// charted is the whole of what the chart shows, the window picks from it
+var charted *chartData
+
+// cursor is the time of the crosshair, zero while it's hidden
+var cursor time.Time
+
+// yAxisModes are the scaling modes picked for the columns
+var yAxisModes = axisModes{}
+
+// rangeKey is the column whose Y range is being typed in, if showRange
+var rangeKey chartKey
+var showRange bool
+
+// SarSar starts the viewer on the input files, merged in time order. In lenient mode
+// (strict is false) unparsable sections and lines are skipped and listed in the diagnostics panel
+func SarSar(inputFiles []string, strict bool) error {
 	var err error
-	file, err = parseSarFile(inputFile)
+	file, err = parseSarFiles(inputFiles, strict)
 	if nil != err {
 		return err
 	}
@@ -19,6 +60,20 @@ func SarSar(inputFile string) error {
 	return startUi()
 }
 
+// PrintDiagnostics parses the input files in lenient mode and writes what was skipped, one line each
+func PrintDiagnostics(inputFiles []string, w io.Writer) error {
+	f, err := parseSarFiles(inputFiles, false)
+	if nil != err {
+		return err
+	}
+	for _, diag := range f.diagnostics {
+		if _, err := fmt.Fprintln(w, diag.String()); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
 func startUi() error {
 	g, err := gocui.NewGui(gocui.OutputNormal)
 	if nil != err {
@@ -26,11 +81,79 @@ func startUi() error {
 	}
 	defer g.Close()
 
+	// Esc closes the Y range prompt, rather than being taken for Alt
+	g.InputEsc = true
 	g.SetManagerFunc(layout)
 
 	if err := g.SetKeybinding("", gocui.KeyCtrlC, gocui.ModNone, quit); nil != err {
 		return err
 	}
+	// the keys of the chart are bound to the menu, which has the focus unless a panel is open,
+	// as those bound to every view would not get typed in the Y range prompt
+	for _, viewName := range []string{"menu", DIAGNOSTICS_VIEW} {
+		if err := g.SetKeybinding(viewName, 'd', gocui.ModNone, toggleDiagnostics); nil != err {
+			return err
+		}
+	}
+	if err := g.SetKeybinding("menu", 'a', gocui.ModNone, toggleDualAxis); nil != err {
+		return err
+	}
+	for _, key := range []rune{'+', '='} {
+		if err := g.SetKeybinding("menu", key, gocui.ModNone, zoomChart(true)); nil != err {
+			return err
+		}
+	}
+	if err := g.SetKeybinding("menu", '-', gocui.ModNone, zoomChart(false)); nil != err {
+		return err
+	}
+	if err := g.SetKeybinding("menu", '0', gocui.ModNone, resetZoom); nil != err {
+		return err
+	}
+	if err := g.SetKeybinding("menu", gocui.KeyArrowLeft, gocui.ModNone, panChart(-1)); nil != err {
+		return err
+	}
+	if err := g.SetKeybinding("menu", gocui.KeyArrowRight, gocui.ModNone, panChart(1)); nil != err {
+		return err
+	}
+	if err := g.SetKeybinding("menu", 'c', gocui.ModNone, toggleCursor); nil != err {
+		return err
+	}
+	if err := g.SetKeybinding("menu", ',', gocui.ModNone, moveCursor(-1)); nil != err {
+		return err
+	}
+	if err := g.SetKeybinding("menu", '.', gocui.ModNone, moveCursor(1)); nil != err {
+		return err
+	}
+	if err := g.SetKeybinding("menu", 'M', gocui.ModNone, jumpToExtreme(true)); nil != err {
+		return err
+	}
+	if err := g.SetKeybinding("menu", 'm', gocui.ModNone, jumpToExtreme(false)); nil != err {
+		return err
+	}
+	if err := g.SetKeybinding("menu", 'y', gocui.ModNone, switchAxisMode(false)); nil != err {
+		return err
+	}
+	if err := g.SetKeybinding("menu", 'Y', gocui.ModNone, switchAxisMode(true)); nil != err {
+		return err
+	}
+	if err := g.SetKeybinding("menu", 'r', gocui.ModNone, promptAxisRange(false)); nil != err {
+		return err
+	}
+	if err := g.SetKeybinding("menu", 'R', gocui.ModNone, promptAxisRange(true)); nil != err {
+		return err
+	}
+	if err := g.SetKeybinding(RANGE_VIEW, gocui.KeyEnter, gocui.ModNone, enterAxisRange); nil != err {
+		return err
+	}
+	if err := g.SetKeybinding(RANGE_VIEW, gocui.KeyEsc, gocui.ModNone, closeAxisRange); nil != err {
+		return err
+	}
+	if err := g.SetKeybinding(DIAGNOSTICS_VIEW, gocui.KeyArrowDown, gocui.ModNone, scrollDiagnostics(1)); nil != err {
+		return err
+	}
+	if err := g.SetKeybinding(DIAGNOSTICS_VIEW, gocui.KeyArrowUp, gocui.ModNone, scrollDiagnostics(-1)); nil != err {
+		return err
+	}
 
 	if err := g.MainLoop(); nil != err && err != gocui.ErrQuit {
 		return err
@@ -40,11 +163,14 @@ func startUi() error {
 }
 
 const (
-	MENU_WIDTH = 30
+	MENU_WIDTH       = 30
+	STATUS_HEIGHT    = 1
+	DIAGNOSTICS_VIEW = "diagnostics"
+	RANGE_VIEW       = "range"
 )
 
 func layout(g *gocui.Gui) error {
-	_, maxY := g.Size()
+	maxX, maxY := g.Size()
 
 	if v, err := g.SetView("menu", -1, -1, MENU_WIDTH, maxY); err != nil {
 		if err != gocui.ErrUnknownView {
@@ -53,10 +179,95 @@ func layout(g *gocui.Gui) error {
 		makeMenuView(g, v)
 	}
 
+	v, err := g.SetView("status", MENU_WIDTH, maxY-STATUS_HEIGHT-1, maxX, maxY)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		v.Frame = false
+		v.BgColor = gocui.ColorBlue
+		v.FgColor = gocui.ColorWhite
+	}
+	v.Clear()
+	fmt.Fprint(v, file.meta.String())
+	if "" != chartStatus {
+		fmt.Fprintf(v, " | %s", chartStatus)
+	}
+	if len(file.diagnostics) > 0 {
+		fmt.Fprintf(v, " | %d diagnostics (d)", len(file.diagnostics))
+	}
+
+	if showDiagnostics {
+		return layoutDiagnostics(g)
+	}
+	g.DeleteView(DIAGNOSTICS_VIEW)
+	if showRange {
+		return layoutRange(g)
+	}
+	g.DeleteView(RANGE_VIEW)
 	g.SetCurrentView("menu")
 	return nil
 }
 
+func layoutDiagnostics(g *gocui.Gui) error {
+	maxX, maxY := g.Size()
+
+	v, err := g.SetView(DIAGNOSTICS_VIEW, MENU_WIDTH+1, 0, maxX-1, maxY-STATUS_HEIGHT-2)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		v.Title = fmt.Sprintf("%d diagnostics, d to close", len(file.diagnostics))
+		v.Wrap = true
+		for _, diag := range file.diagnostics {
+			fmt.Fprintln(v, diag.String())
+		}
+		if len(file.diagnostics) == 0 {
+			fmt.Fprintln(v, "no diagnostics, every line was parsed")
+		}
+	}
+	g.SetViewOnTop(DIAGNOSTICS_VIEW)
+	g.SetCurrentView(DIAGNOSTICS_VIEW)
+	return nil
+}
+
+// layoutRange prompts for the Y range of rangeKey over the chart
+func layoutRange(g *gocui.Gui) error {
+	maxX, _ := g.Size()
+
+	v, err := g.SetView(RANGE_VIEW, MENU_WIDTH+1, CHART_HEIGHT/2-1, maxX-1, CHART_HEIGHT/2+1)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		v.Title = fmt.Sprintf("Y range of %s: min max, Enter to apply, Esc to cancel", rangeKey.col)
+		v.Editable = true
+		if mode := yAxisModes.get(rangeKey); mode.min < mode.max {
+			text := formatAxisRange(mode.min, mode.max)
+			fmt.Fprint(v, text)
+			v.SetCursor(len(text), 0)
+		}
+	}
+	g.SetViewOnTop(RANGE_VIEW)
+	g.SetCurrentView(RANGE_VIEW)
+	return nil
+}
+
+func toggleDiagnostics(g *gocui.Gui, v *gocui.View) error {
+	showDiagnostics = !showDiagnostics
+	return nil
+}
+
+func scrollDiagnostics(delta int) func(*gocui.Gui, *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		ox, oy := v.Origin()
+		if oy+delta < 0 || oy+delta >= len(file.diagnostics) {
+			return nil
+		}
+		return v.SetOrigin(ox, oy+delta)
+	}
+}
+
 func makeMenuView(g *gocui.Gui, v *gocui.View) error {
 	v.Highlight = true
 	v.SelBgColor = gocui.ColorGreen
@@ -69,22 +280,27 @@ func makeMenuView(g *gocui.Gui, v *gocui.View) error {
 	}
 	treeRoot.Expand()
 
-	for sectionId := range file.sections {
-		name := section2Name[sectionId]
+	for _, sectionId := range file.sectionOrder {
+		name := sectionName(sectionId)
 		section := file.sections[sectionId]
 
-		var nodes []*ui.TreeNode
-		if len(section.records) > 0 {
-			for col := range section.records[0].data {
-				nodes = append(nodes, &ui.TreeNode{
-					Name: col,
-				})
-			}
+		if NO_INSTANCE == section.instanceColumn {
+			treeRoot.AddSubNode(name, makeColumnNodes(sectionId, NO_INSTANCE))
+			continue
+		}
+
+		var instanceNodes []*ui.TreeNode
+		for _, instance := range section.instanceOrder {
+			instanceNodes = append(instanceNodes, &ui.TreeNode{
+				Name:  instance,
+				Nodes: makeColumnNodes(sectionId, instance),
+			})
 		}
-		treeRoot.AddSubNode(name, nodes)
+		treeRoot.AddSubNode(name, instanceNodes)
 	}
 
 	treeRoot.SetEnterCallback(menuEnter)
+	treeRoot.SetMarkCallback(menuMark)
 
 	if err := treeRoot.Render(g, v); nil != err {
 		return err
@@ -92,56 +308,435 @@ func makeMenuView(g *gocui.Gui, v *gocui.View) error {
 	return nil
 }
 
+func makeColumnNodes(sectionId int, instance string) []*ui.TreeNode {
+	var nodes []*ui.TreeNode
+	def := sectionRegistry[sectionId]
+	series := file.sections[sectionId].instances[instance]
+	if nil == series || series.len() == 0 {
+		return nodes
+	}
+	for _, col := range series.schema.columns {
+		if col.text || !def.isChartable(col.name) {
+			continue
+		}
+		nodes = append(nodes, &ui.TreeNode{
+			Name: col.name,
+		})
+	}
+	return nodes
+}
+
 func menuEnter(g *gocui.Gui, v *gocui.View, keys []string) error {
-	if len(keys) != 3 {
-		return fmt.Errorf("unexpected menu key depth: %+v", keys)
+	key, err := makeChartKey(keys)
+	if nil != err {
+		return err
 	}
+	tableKey = key
+	chartKeys = []chartKey{key}
+	return renderChart(g)
+}
 
-	sectionId, err := file.getSectionId(keys[1])
+// menuMark adds a column to the overlaid ones or takes it out, the marks stay as they were if the
+// chart can't be drawn
+func menuMark(g *gocui.Gui, v *gocui.View, keys []string, marked bool) error {
+	key, err := makeChartKey(keys)
 	if nil != err {
 		return err
 	}
+	var marks []chartKey
+	if marked {
+		if len(markedKeys) >= len(CHART_COLORS) {
+			chartStatus = fmt.Sprintf("at most %d series can be overlaid", len(CHART_COLORS))
+			return errors.New(chartStatus)
+		}
+		marks = append(append(marks, markedKeys...), key)
+	} else {
+		for _, markedKey := range markedKeys {
+			if markedKey != key {
+				marks = append(marks, markedKey)
+			}
+		}
+	}
+
+	prevMarks, prevTableKey, prevChartKeys, prevCharted := markedKeys, tableKey, chartKeys, charted
+	markedKeys = marks
+	tableKey = key
+	chartKeys = []chartKey{key}
+	if len(markedKeys) > 0 {
+		chartKeys = append([]chartKey{}, markedKeys...)
+	}
+	if err := renderChart(g); nil != err {
+		markedKeys, tableKey, chartKeys, charted = prevMarks, prevTableKey, prevChartKeys, prevCharted
+		return err
+	}
+	return nil
+}
 
-	labels, values, err := file.getDataSeriesByName(keys[1], keys[0])
+func toggleDualAxis(g *gocui.Gui, v *gocui.View) error {
+	dualAxis = !dualAxis
+	return renderChart(g)
+}
+
+// zoomChart narrows or widens the window around the cursor, or around its middle if the cursor is not in it
+func zoomChart(zoomIn bool) func(*gocui.Gui, *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if nil == charted {
+			return nil
+		}
+		lo, hi := window.indexes(charted.times)
+		center := (lo + hi) / 2
+		if !cursor.IsZero() {
+			if idx := charted.timeIndex(cursor); idx >= lo && idx < hi {
+				center = idx
+			}
+		}
+		window = window.zoom(charted.times, center, zoomIn)
+		return renderChart(g)
+	}
+}
+
+func panChart(delta int) func(*gocui.Gui, *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if nil == charted {
+			return nil
+		}
+		window = window.pan(charted.times, delta)
+		return renderChart(g)
+	}
+}
+
+// toggleCursor shows the crosshair in the middle of the window, or hides it
+func toggleCursor(g *gocui.Gui, v *gocui.View) error {
+	if nil == charted || len(charted.times) == 0 {
+		return nil
+	}
+	if !cursor.IsZero() {
+		cursor = time.Time{}
+		return renderChart(g)
+	}
+	lo, hi := window.indexes(charted.times)
+	cursor = charted.times[(lo+hi)/2]
+	return renderChart(g)
+}
+
+// moveCursor moves the crosshair by samples, panning the window along
+func moveCursor(delta int) func(*gocui.Gui, *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if nil == charted || len(charted.times) == 0 {
+			return nil
+		}
+		if cursor.IsZero() {
+			return toggleCursor(g, v)
+		}
+		idx := charted.timeIndex(cursor) + delta
+		if idx < 0 || idx >= len(charted.times) {
+			return nil
+		}
+		return placeCursor(g, idx)
+	}
+}
+
+// jumpToExtreme puts the crosshair on the highest or lowest value of the first charted series
+func jumpToExtreme(max bool) func(*gocui.Gui, *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		if nil == charted {
+			return nil
+		}
+		if idx := charted.extreme(max); idx >= 0 {
+			return placeCursor(g, idx)
+		}
+		return nil
+	}
+}
+
+func placeCursor(g *gocui.Gui, idx int) error {
+	cursor = charted.times[idx]
+	window = window.follow(charted.times, idx)
+	return renderChart(g)
+}
+
+// axisKey is the column scaling the left or right Y axis, false if there is no such axis
+func axisKey(right bool) (chartKey, bool) {
+	if right {
+		if !dualAxis || len(chartKeys) < 2 {
+			return chartKey{}, false
+		}
+		return chartKeys[1], true
+	}
+	if len(chartKeys) == 0 {
+		return chartKey{}, false
+	}
+	return chartKeys[0], true
+}
+
+// switchAxisMode switches the column of the left or right Y axis to its next scaling mode
+func switchAxisMode(right bool) func(*gocui.Gui, *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		key, found := axisKey(right)
+		if !found {
+			return nil
+		}
+		yAxisModes.next(key)
+		return renderChart(g)
+	}
+}
+
+// promptAxisRange asks for the Y range of the column of the left or right Y axis
+func promptAxisRange(right bool) func(*gocui.Gui, *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		key, found := axisKey(right)
+		if !found {
+			return nil
+		}
+		rangeKey = key
+		showRange = true
+		return nil
+	}
+}
+
+// enterAxisRange scales the column on the range typed in, the prompt stays with the error if it's not one
+func enterAxisRange(g *gocui.Gui, v *gocui.View) error {
+	text := strings.TrimSpace(v.Buffer())
+	if "" == text {
+		return closeAxisRange(g, v)
+	}
+	min, max, err := parseAxisRange(text)
+	if nil == err {
+		err = yAxisModes.setRange(rangeKey, min, max)
+	}
+	if nil != err {
+		v.Title = fmt.Sprintf("%s, Esc to cancel", err)
+		return nil
+	}
+	if err := closeAxisRange(g, v); nil != err {
+		return err
+	}
+	return renderChart(g)
+}
+
+func closeAxisRange(g *gocui.Gui, v *gocui.View) error {
+	showRange = false
+	return nil
+}
+
+func resetZoom(g *gocui.Gui, v *gocui.View) error {
+	window = chartWindow{}
+	return renderChart(g)
+}
+
+// renderChart draws the charted columns, the status and the table within the window
+func renderChart(g *gocui.Gui) error {
+	if len(chartKeys) == 0 {
+		return nil
+	}
+	data, err := file.makeChartData(chartKeys, dualAxis)
 	if nil != err {
 		return err
 	}
+	charted = data
+	for idx, key := range data.keys {
+		data.series[idx].axis = yAxisModes.get(key)
+	}
+	lo, hi := window.indexes(data.times)
+	visible := data.slice(lo, hi)
+	cursorIdx := -1
+	if !cursor.IsZero() {
+		if idx := data.timeIndex(cursor); idx >= lo && idx < hi {
+			cursorIdx = idx - lo
+		}
+	}
 
-	if err := renderChartView(g, labels, values); nil != err {
+	if err := renderChartView(g, visible.labels, visible.series, visible.breaks, cursorIdx); nil != err {
 		return err
 	}
 
-	return renderTableView(g, sectionId)
+	if len(chartKeys) == 1 {
+		key := chartKeys[0]
+		segments := 1
+		if series, err := file.getSeries(key.section, key.instance); nil == err {
+			segments = len(series.segments())
+		}
+		chartStatus = makeAverageStatus(key.section, key.instance, key.col, data.series[0].values, segments)
+	} else if dualAxis {
+		chartStatus = fmt.Sprintf("%d series overlaid, dual Y axis (a)", len(chartKeys))
+	} else {
+		chartStatus = fmt.Sprintf("%d series overlaid, shared Y axis (a)", len(chartKeys))
+	}
+	if key, found := axisKey(true); found {
+		chartStatus = fmt.Sprintf("%s, Y %s/%s (y/Y, r/R)", chartStatus, yAxisModes.get(chartKeys[0]), yAxisModes.get(key))
+	} else {
+		chartStatus = fmt.Sprintf("%s, Y %s (y, r)", chartStatus, yAxisModes.get(chartKeys[0]))
+	}
+	if window.isZoomed() {
+		// ahead of the rest, which may not fit
+		chartStatus = fmt.Sprintf("%s, %d of %d samples (0 resets) | %s", window, hi-lo, len(data.times), chartStatus)
+	}
+
+	series, err := file.getSeries(tableKey.section, tableKey.instance)
+	if nil != err {
+		return err
+	}
+	return renderTableView(g, series, window, cursor)
 }
 
-func renderTableView(g *gocui.Gui, sectionId int) error {
+// makeAverageStatus compares the mean of the charted values with sar's own average,
+// a mismatch usually means a truncated capture. The mean spans the segments of the series, which
+// are told when there are several.
+func makeAverageStatus(sectionName, instance, col string, values []float64, segments int) string {
+	sum := float64(0)
+	count := 0
+	for _, val := range values {
+		if !math.IsNaN(val) {
+			sum += val
+			count++
+		}
+	}
+	if 0 == count {
+		return fmt.Sprintf("%s no values", describeColumn(sectionName, col))
+	}
+	mean := sum / float64(count)
+
+	status := fmt.Sprintf("%s mean %.2f", describeColumn(sectionName, col), mean)
+	if segments > 1 {
+		status = fmt.Sprintf("%s over %d segments", status, segments)
+	}
+	if missing := len(values) - count; missing > 0 {
+		status = fmt.Sprintf("%s (%d missing)", status, missing)
+	}
+	if avg, found := file.getAverageByName(sectionName, instance, col); found {
+		status = fmt.Sprintf("%s, sar average %.2f", status, avg)
+		// sar prints 2 decimals, allow for rounding, human-readable sizes have only 1 decimal
+		tolerance := 0.01 + 0.01*math.Abs(avg)
+		if UNIT_BYTES == file.getUnit(sectionName, col) {
+			tolerance = 0.05 * math.Abs(avg)
+		}
+		if math.Abs(mean-avg) > tolerance {
+			status = fmt.Sprintf("%s (MISMATCH, capture may be truncated)", status)
+		}
+	}
+	return status
+}
+
+// describeColumn gives the column name with the unit and description from its section definition
+func describeColumn(sectionName, col string) string {
+	var info sectionColumn
+	if sectionId, found := getSectionIdByName(sectionName); found {
+		info, _ = getSectionColumn(sectionId, col)
+	}
+	// human-readable values are normalized to bytes, whatever the column name says
+	if unit := file.getUnit(sectionName, col); UNIT_NONE != unit {
+		info.Unit = unit
+	}
+	desc := col
+	if "" != info.Unit {
+		desc = fmt.Sprintf("%s [%s]", desc, info.Unit)
+	}
+	if "" != info.Description {
+		desc = fmt.Sprintf("%s %s,", desc, info.Description)
+	}
+	return desc
+}
+
+// TABLE_HEADER_LINES are the column names and the line under them
+const TABLE_HEADER_LINES = 2
+
+// lines between the segments of a series, in place of a row index
+const (
+	TABLE_GAP_ROW     = -1
+	TABLE_RESTART_ROW = -2
+)
+
+// renderTableView lists the samples of the series within the window, scrolled to and highlighting
+// the row of the cursor unless it's zero
+func renderTableView(g *gocui.Gui, series *sarSeries, window chartWindow, cursor time.Time) error {
 	maxX, maxY := g.Size()
 
-	section := file.sections[sectionId]
 	tbl := table.New().SetWidth(maxX)
 
-	if len(section.records) == 0 {
+	if series.len() == 0 {
 		return nil
 	}
 
-	for col := range section.records[0].data {
-		tbl.AddCol(fmt.Sprintf("%8s", col))
+	// the date tells the rows apart when the series spans several days, as in the window of the chart
+	layout := "15:04:05"
+	if from, to := series.times[0], series.times[series.len()-1]; from.YearDay() != to.YearDay() || from.Year() != to.Year() {
+		layout = "Jan 02 15:04:05"
+	}
+	timeWidth := len(layout)
+
+	tbl.AddCol(fmt.Sprintf("%*s", timeWidth, "time"))
+	for _, col := range series.schema.columns {
+		tbl.AddCol(fmt.Sprintf("%8s", col.name))
+	}
+
+	var rows []int
+	cursorRow := -1
+	// a cursor out of the window is not in the table
+	hasCursor := !cursor.IsZero() && window.contains(cursor)
+	for row, ts := range series.times {
+		if !window.contains(ts) {
+			continue
+		}
+		if b, found := series.breakBefore(row); found && len(rows) > 0 {
+			if b.restart {
+				rows = append(rows, TABLE_RESTART_ROW)
+			} else {
+				rows = append(rows, TABLE_GAP_ROW)
+			}
+		}
+		if cursorRow < 0 && hasCursor && !ts.Before(cursor) {
+			cursorRow = len(rows)
+		}
+		rows = append(rows, row)
+	}
+
+	y0, y1 := CHART_HEIGHT+1, maxY-STATUS_HEIGHT-1
+	height := y1 - y0 - 1 - TABLE_HEADER_LINES
+	// sar's average keeps the last line, whatever rows are above it
+	if nil != series.average {
+		height--
+	}
+	first, last := visibleTableRows(len(rows), cursorRow, height)
+	for _, row := range rows[first:last] {
+		if row < 0 {
+			mark := "gap"
+			if TABLE_RESTART_ROW == row {
+				mark = "restart"
+			}
+			vals := []interface{}{fmt.Sprintf("%*s", timeWidth, mark)}
+			for range series.schema.columns {
+				vals = append(vals, fmt.Sprintf("%8s", ""))
+			}
+			tbl.AddRow(vals...)
+			continue
+		}
+		vals := []interface{}{series.times[row].Format(layout)}
+		for col := range series.schema.columns {
+			vals = append(vals, fmt.Sprintf("%8s", series.format(col, row)))
+		}
+		tbl.AddRow(vals...)
 	}
 
-	for _, rec := range section.records {
-		var vals []interface{}
-		for _, val := range rec.data {
-			vals = append(vals, fmt.Sprintf("%8s", val))
+	if nil != series.average {
+		vals := []interface{}{fmt.Sprintf("%*s", timeWidth, "Average")}
+		for _, col := range series.schema.columns {
+			vals = append(vals, fmt.Sprintf("%8s", series.average[col.name]))
 		}
 		tbl.AddRow(vals...)
 	}
 
 	g.DeleteView("table")
-	if v, err := g.SetView("table", MENU_WIDTH+1, 11, maxX-1, maxY-1); err != nil {
+	if v, err := g.SetView("table", MENU_WIDTH+1, y0, maxX-1, y1); err != nil {
 		if err != gocui.ErrUnknownView {
 			return err
 		}
 		v.Frame = false
+		if cursorRow >= 0 {
+			v.Highlight = true
+			v.SelBgColor = gocui.ColorGreen
+			v.SelFgColor = gocui.ColorBlack
+			v.SetCursor(0, TABLE_HEADER_LINES+cursorRow-first)
+		}
 
 		g.Update(func(gui *gocui.Gui) error {
 			tbl.Format().Fprint(v)
@@ -151,6 +746,23 @@ func renderTableView(g *gocui.Gui, sectionId int) error {
 	return nil
 }
 
+// visibleTableRows picks the rows [first, last) which fit in the height of the table. It has no
+// scrolling of its own, the rows above the cursor are left out instead.
+func visibleTableRows(count, cursorRow, height int) (int, int) {
+	if height < 1 {
+		height = 1
+	}
+	first := 0
+	if cursorRow >= height {
+		first = cursorRow - height/2
+	}
+	last := first + height
+	if last > count {
+		last = count
+	}
+	return first, last
+}
+
 func quit(g *gocui.Gui, v *gocui.View) error {
 	return gocui.ErrQuit
 }
@@ -0,0 +1,63 @@
+package sarsar
+
+import (
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeColumnNodes(t *testing.T) {
+	f, err := parseSarReader(strings.NewReader(sarCpuAll), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	file = f
+	defer func() {
+		file = nil
+	}()
+
+	var names []string
+	for _, node := range makeColumnNodes(SECTION_CPU_UTIL, "0") {
+		names = append(names, node.Name)
+	}
+	// the instance column is not charted
+	assert.Equal(t, strings.Fields("%usr %nice %sys %iowait %steal %irq %soft %guest %gnice %idle"), names)
+}
+
+func TestMenuMarkRollback(t *testing.T) {
+	f, err := parseSarReader(strings.NewReader(sarCpuAll), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	file = f
+	marked := chartKey{section: sectionName(SECTION_CPU_UTIL), instance: "all", col: "%usr"}
+	markedKeys, chartKeys = []chartKey{marked}, []chartKey{marked}
+	defer func() {
+		file, markedKeys, chartKeys, tableKey = nil, nil, nil, chartKey{}
+	}()
+
+	// there is no CPU 9 to chart, nothing changes
+	err = menuMark(nil, nil, []string{"%usr", "9", sectionName(SECTION_CPU_UTIL), "root"}, true)
+	assert.Error(t, err)
+	assert.Equal(t, []chartKey{marked}, markedKeys)
+	assert.Equal(t, []chartKey{marked}, chartKeys)
+	assert.Equal(t, chartKey{}, tableKey)
+}
+
+func TestVisibleTableRows(t *testing.T) {
+	first, last := visibleTableRows(100, -1, 10)
+	assert.Equal(t, []int{0, 10}, []int{first, last})
+	first, last = visibleTableRows(5, -1, 10)
+	assert.Equal(t, []int{0, 5}, []int{first, last})
+
+	// the cursor is brought to the middle once it would be out of sight
+	first, last = visibleTableRows(100, 9, 10)
+	assert.Equal(t, []int{0, 10}, []int{first, last})
+	first, last = visibleTableRows(100, 10, 10)
+	assert.Equal(t, []int{5, 15}, []int{first, last})
+	first, last = visibleTableRows(100, 98, 10)
+	assert.Equal(t, []int{93, 100}, []int{first, last})
+
+	first, last = visibleTableRows(100, 5, 0)
+	assert.Equal(t, []int{5, 6}, []int{first, last})
+}
@@ -0,0 +1,807 @@
+package sarsar
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// sectionColumn documents one column of a section
+type sectionColumn struct {
+	Name        string `json:"name"`
+	Unit        string `json:"unit,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// sectionDef declares a sar section, which is recognized by the leading columns of its header line
+type sectionDef struct {
+	Name string `json:"name"`
+	// any of the signatures may start the header line, e.g. both "kbmemfree kbavail" and "kbmemfree kbmemused"
+	Signatures     [][]string `json:"signatures"`
+	InstanceColumn string     `json:"instance_column,omitempty"`
+	// other names of the instance column, e.g. MOUNTPOINT for FILESYSTEM with "sar -F MOUNT"
+	InstanceAliases []string `json:"instance_aliases,omitempty"`
+	// columns added to the instance name when the instance column alone is ambiguous or cryptic
+	InstanceDetails []string `json:"instance_details,omitempty"`
+	// non-numeric columns, which are not charted, the trailing ones may contain spaces
+	TextColumns []string        `json:"text_columns,omitempty"`
+	Columns     []sectionColumn `json:"columns,omitempty"`
+}
+
+// sectionRegistry holds the known sections, the SECTION_* constants index the built-in ones
+// and user definitions are appended after them
+var sectionRegistry = []*sectionDef{
+	SECTION_CPU_UTIL: {
+		Name:           "CPU util",
+		Signatures:     [][]string{{"CPU", "%usr"}, {"CPU", "%user"}},
+		InstanceColumn: "CPU",
+		Columns: []sectionColumn{
+			{"CPU", "", "processor number, all for the average of every processor"},
+			{"%usr", "%", "CPU utilization at the user level"},
+			{"%user", "%", "CPU utilization at the user level, including virtual processors"},
+			{"%nice", "%", "CPU utilization at the user level with nice priority"},
+			{"%sys", "%", "CPU utilization at the system level, excluding interrupts"},
+			{"%system", "%", "CPU utilization at the system level"},
+			{"%iowait", "%", "idle time with an outstanding disk I/O request"},
+			{"%steal", "%", "involuntary wait while the hypervisor serviced another virtual processor"},
+			{"%irq", "%", "time servicing hardware interrupts"},
+			{"%soft", "%", "time servicing software interrupts"},
+			{"%guest", "%", "time running a virtual processor"},
+			{"%gnice", "%", "time running a niced guest"},
+			{"%idle", "%", "idle time without an outstanding disk I/O request"},
+		},
+	},
+	SECTION_TASK_CREATION_AND_SYS_SWITCH: {
+		Name:       "Task Creation & Switch",
+		Signatures: [][]string{{"proc/s", "cswch/s"}},
+		Columns: []sectionColumn{
+			{"proc/s", "/s", "tasks created"},
+			{"cswch/s", "/s", "context switches"},
+		},
+	},
+	SECTION_SWAPPING: {
+		Name:       "Swapping statics",
+		Signatures: [][]string{{"pswpin/s", "pswpout/s"}},
+		Columns: []sectionColumn{
+			{"pswpin/s", "pages/s", "swap pages brought in"},
+			{"pswpout/s", "pages/s", "swap pages brought out"},
+		},
+	},
+	SECTION_PAGING: {
+		Name:       "Paging statics",
+		Signatures: [][]string{{"pgpgin/s", "pgpgout/s"}},
+		Columns: []sectionColumn{
+			{"pgpgin/s", "kB/s", "paged in from disk"},
+			{"pgpgout/s", "kB/s", "paged out to disk"},
+			{"fault/s", "/s", "page faults, major and minor"},
+			{"majflt/s", "/s", "major faults, which loaded a page from disk"},
+			{"pgfree/s", "pages/s", "pages placed on the free list"},
+			{"pgscank/s", "pages/s", "pages scanned by kswapd"},
+			{"pgscand/s", "pages/s", "pages scanned directly"},
+			{"pgsteal/s", "pages/s", "pages reclaimed from cache"},
+			{"%vmeff", "%", "pages stolen over pages scanned, page reclaim efficiency"},
+		},
+	},
+	SECTION_IO: {
+		Name:       "IO statics",
+		Signatures: [][]string{{"tps", "rtps"}},
+		Columns: []sectionColumn{
+			{"tps", "/s", "transfers issued to physical devices"},
+			{"rtps", "/s", "read requests issued to physical devices"},
+			{"wtps", "/s", "write requests issued to physical devices"},
+			{"dtps", "/s", "discard requests issued to physical devices"},
+			{"bread/s", "blocks/s", "data read from the devices, in 512-byte blocks"},
+			{"bwrtn/s", "blocks/s", "data written to the devices, in 512-byte blocks"},
+			{"bdscd/s", "blocks/s", "data discarded on the devices, in 512-byte blocks"},
+		},
+	},
+	SECTION_MEM_UTIL: {
+		Name:       "Memory util",
+		Signatures: [][]string{{"kbmemfree", "kbavail"}, {"kbmemfree", "kbmemused"}},
+		Columns: []sectionColumn{
+			{"kbmemfree", "kB", "free memory"},
+			{"kbavail", "kB", "memory available without swapping"},
+			{"kbmemused", "kB", "used memory"},
+			{"%memused", "%", "used memory"},
+			{"kbbuffers", "kB", "memory used as buffers by the kernel"},
+			{"kbcached", "kB", "memory used to cache data by the kernel"},
+			{"kbcommit", "kB", "memory needed for the current workload"},
+			{"%commit", "%", "memory needed for the current workload, of memory plus swap"},
+			{"kbactive", "kB", "memory used more recently"},
+			{"kbinact", "kB", "memory used less recently"},
+			{"kbdirty", "kB", "memory waiting to get written back to disk"},
+			{"kbanonpg", "kB", "non-file backed pages mapped into page tables"},
+			{"kbslab", "kB", "memory used by the kernel to cache data structures"},
+			{"kbkstack", "kB", "memory used for kernel stacks"},
+			{"kbpgtbl", "kB", "memory dedicated to the lowest level of page tables"},
+			{"kbvmused", "kB", "memory used by the vmalloc area"},
+		},
+	},
+	SECTION_MEM: {
+		Name:       "Memory statistics",
+		Signatures: [][]string{{"frmpg/s", "bufpg/s"}},
+		Columns: []sectionColumn{
+			{"frmpg/s", "pages/s", "memory pages freed, negative if allocated"},
+			{"bufpg/s", "pages/s", "memory pages used as buffers"},
+			{"campg/s", "pages/s", "memory pages cached"},
+		},
+	},
+	SECTION_SWAP_SPACE_UTIL: {
+		Name:       "Swap space util",
+		Signatures: [][]string{{"kbswpfree", "kbswpused"}},
+		Columns: []sectionColumn{
+			{"kbswpfree", "kB", "free swap space"},
+			{"kbswpused", "kB", "used swap space"},
+			{"%swpused", "%", "used swap space"},
+			{"kbswpcad", "kB", "cached swap memory"},
+			{"%swpcad", "%", "cached swap memory of used swap space"},
+		},
+	},
+	SECTION_HUGEPAGES_UTIL: {
+		Name:       "Hugepages util",
+		Signatures: [][]string{{"kbhugfree", "kbhugused"}},
+		Columns: []sectionColumn{
+			{"kbhugfree", "kB", "hugepages memory not yet allocated"},
+			{"kbhugused", "kB", "hugepages memory allocated"},
+			{"%hugused", "%", "hugepages memory allocated"},
+			{"kbhugrsvd", "kB", "hugepages memory reserved"},
+			{"kbhugsurp", "kB", "hugepages memory surplus"},
+		},
+	},
+	SECTION_KERNEL_TABLE_STATUS: {
+		Name:       "inode/file/kernel-tables",
+		Signatures: [][]string{{"dentunusd", "file-nr"}},
+		Columns: []sectionColumn{
+			{"dentunusd", "", "unused cache entries in the directory cache"},
+			{"file-nr", "", "file handles used by the system"},
+			{"inode-nr", "", "inode handlers used by the system"},
+			{"pty-nr", "", "pseudo-terminals used by the system"},
+		},
+	},
+	SECTION_QLEN_LOADAVG: {
+		Name:       "Queue-length & load-avg",
+		Signatures: [][]string{{"runq-sz", "plist-sz"}},
+		Columns: []sectionColumn{
+			{"runq-sz", "", "tasks running or waiting for run time"},
+			{"plist-sz", "", "tasks in the task list"},
+			{"ldavg-1", "", "load average for the last minute"},
+			{"ldavg-5", "", "load average for the past 5 minutes"},
+			{"ldavg-15", "", "load average for the past 15 minutes"},
+			{"blocked", "", "tasks blocked, waiting for I/O to complete"},
+		},
+	},
+	SECTION_TTY_DEV: {
+		Name:           "TTY devices activity",
+		Signatures:     [][]string{{"TTY", "rcvin/s"}},
+		InstanceColumn: "TTY",
+		Columns: []sectionColumn{
+			{"TTY", "", "serial line"},
+			{"rcvin/s", "/s", "receive interrupts"},
+			{"xmtin/s", "/s", "transmit interrupts"},
+			{"framerr/s", "/s", "frame errors"},
+			{"prtyerr/s", "/s", "parity errors"},
+			{"brk/s", "/s", "breaks"},
+			{"ovrun/s", "/s", "overrun errors"},
+		},
+	},
+	SECTION_BLOCK_DEV: {
+		Name:           "Block dev activity",
+		Signatures:     [][]string{{"DEV", "tps"}},
+		InstanceColumn: "DEV",
+		Columns: []sectionColumn{
+			{"DEV", "", "block device"},
+			{"tps", "/s", "transfers issued to the device"},
+			{"rd_sec/s", "sectors/s", "sectors read from the device"},
+			{"wr_sec/s", "sectors/s", "sectors written to the device"},
+			{"rkB/s", "kB/s", "read from the device"},
+			{"wkB/s", "kB/s", "written to the device"},
+			{"dkB/s", "kB/s", "discarded for the device"},
+			{"avgrq-sz", "sectors", "average size of the requests issued to the device"},
+			{"areq-sz", "kB", "average size of the requests issued to the device"},
+			{"avgqu-sz", "", "average queue length of the requests issued to the device"},
+			{"aqu-sz", "", "average queue length of the requests issued to the device"},
+			{"await", "ms", "average time for requests to be served, including queueing"},
+			{"svctm", "ms", "average service time, deprecated"},
+			{"%util", "%", "elapsed time during which requests were issued to the device"},
+		},
+	},
+	SECTION_NETWORK_DEV: {
+		Name:           "Network statistics",
+		Signatures:     [][]string{{"IFACE", "rxpck/s"}},
+		InstanceColumn: "IFACE",
+		Columns: []sectionColumn{
+			{"IFACE", "", "network interface"},
+			{"rxpck/s", "/s", "packets received"},
+			{"txpck/s", "/s", "packets transmitted"},
+			{"rxkB/s", "kB/s", "received"},
+			{"txkB/s", "kB/s", "transmitted"},
+			{"rxcmp/s", "/s", "compressed packets received"},
+			{"txcmp/s", "/s", "compressed packets transmitted"},
+			{"rxmcst/s", "/s", "multicast packets received"},
+			{"%ifutil", "%", "utilization of the network interface"},
+		},
+	},
+	SECTION_NETWORK_EDEV: {
+		Name:           "Network device errors",
+		Signatures:     [][]string{{"IFACE", "rxerr/s"}},
+		InstanceColumn: "IFACE",
+		Columns: []sectionColumn{
+			{"IFACE", "", "network interface"},
+			{"rxerr/s", "/s", "bad packets received"},
+			{"txerr/s", "/s", "errors while transmitting packets"},
+			{"coll/s", "/s", "collisions while transmitting packets"},
+			{"rxdrop/s", "/s", "received packets dropped for lack of buffer space"},
+			{"txdrop/s", "/s", "transmitted packets dropped for lack of buffer space"},
+			{"txcarr/s", "/s", "carrier errors while transmitting packets"},
+			{"rxfram/s", "/s", "frame alignment errors on received packets"},
+			{"rxfifo/s", "/s", "FIFO overrun errors on received packets"},
+			{"txfifo/s", "/s", "FIFO overrun errors on transmitted packets"},
+		},
+	},
+	SECTION_NETWORK_SOCK: {
+		Name:       "Network sockets",
+		Signatures: [][]string{{"totsck", "tcpsck"}},
+		Columns: []sectionColumn{
+			{"totsck", "", "sockets used by the system"},
+			{"tcpsck", "", "TCP sockets in use"},
+			{"udpsck", "", "UDP sockets in use"},
+			{"rawsck", "", "RAW sockets in use"},
+			{"ip-frag", "", "IP fragments queued"},
+			{"tcp-tw", "", "TCP sockets in TIME_WAIT"},
+		},
+	},
+	SECTION_NETWORK_SOFT: {
+		Name:           "Software-based network processing",
+		Signatures:     [][]string{{"CPU", "total/s"}},
+		InstanceColumn: "CPU",
+		Columns: []sectionColumn{
+			{"CPU", "", "processor number, all for the average of every processor"},
+			{"total/s", "/s", "network frames processed"},
+			{"dropd/s", "/s", "network frames dropped for lack of room in the processing queue"},
+			{"squeezd/s", "/s", "times softirq had more work when its budget ran out"},
+			{"rx_rcv/s", "/s", "times the CPU was woken up to process packets"},
+			{"flw_lim/s", "/s", "times the flow limit was reached"},
+		},
+	},
+	SECTION_NETWORK_NFS: {
+		Name:       "NFS client",
+		Signatures: [][]string{{"call/s", "retrans/s"}},
+		Columns: []sectionColumn{
+			{"call/s", "/s", "RPC requests made"},
+			{"retrans/s", "/s", "RPC requests retransmitted"},
+			{"read/s", "/s", "read calls made"},
+			{"write/s", "/s", "write calls made"},
+			{"access/s", "/s", "access calls made"},
+			{"getatt/s", "/s", "getattr calls made"},
+		},
+	},
+	SECTION_NETWORK_NFSD: {
+		Name:       "NFS server",
+		Signatures: [][]string{{"scall/s", "badcall/s"}},
+		Columns: []sectionColumn{
+			{"scall/s", "/s", "RPC requests received"},
+			{"badcall/s", "/s", "bad RPC requests received"},
+			{"packet/s", "/s", "network packets received"},
+			{"udp/s", "/s", "UDP packets received"},
+			{"tcp/s", "/s", "TCP packets received"},
+			{"hit/s", "/s", "reply cache hits"},
+			{"miss/s", "/s", "reply cache misses"},
+			{"sread/s", "/s", "read calls received"},
+			{"swrite/s", "/s", "write calls received"},
+			{"saccess/s", "/s", "access calls received"},
+			{"sgetatt/s", "/s", "getattr calls received"},
+		},
+	},
+	SECTION_NETWORK_IP: {
+		Name:       "IPv4 traffic",
+		Signatures: [][]string{{"irec/s", "fwddgm/s"}},
+		Columns: []sectionColumn{
+			{"irec/s", "/s", "input datagrams received from interfaces"},
+			{"fwddgm/s", "/s", "input datagrams forwarded"},
+			{"idel/s", "/s", "input datagrams delivered to IP user-protocols"},
+			{"orq/s", "/s", "IP datagrams supplied for transmission by local user-protocols"},
+			{"asmrq/s", "/s", "fragments received needing reassembly"},
+			{"asmok/s", "/s", "IP datagrams reassembled"},
+			{"fragok/s", "/s", "IP datagrams fragmented"},
+			{"fragcrt/s", "/s", "IP datagram fragments generated"},
+		},
+	},
+	SECTION_NETWORK_EIP: {
+		Name:       "IPv4 errors",
+		Signatures: [][]string{{"ihdrerr/s", "iadrerr/s"}},
+		Columns: []sectionColumn{
+			{"ihdrerr/s", "/s", "input datagrams discarded for errors in their IP headers"},
+			{"iadrerr/s", "/s", "input datagrams discarded for an invalid destination address"},
+			{"iukwnpr/s", "/s", "input datagrams discarded for an unknown or unsupported protocol"},
+			{"idisc/s", "/s", "input datagrams discarded for lack of buffer space"},
+			{"odisc/s", "/s", "output datagrams discarded for lack of buffer space"},
+			{"onort/s", "/s", "datagrams discarded because no route could be found"},
+			{"asmf/s", "/s", "IP reassembly failures"},
+			{"fragf/s", "/s", "datagrams discarded because they could not be fragmented"},
+		},
+	},
+	SECTION_NETWORK_ICMP: {
+		Name:       "ICMPv4 traffic",
+		Signatures: [][]string{{"imsg/s", "omsg/s"}},
+		Columns: []sectionColumn{
+			{"imsg/s", "/s", "ICMP messages received"},
+			{"omsg/s", "/s", "ICMP messages sent"},
+			{"iech/s", "/s", "ICMP Echo request messages received"},
+			{"iechr/s", "/s", "ICMP Echo reply messages received"},
+			{"oech/s", "/s", "ICMP Echo request messages sent"},
+			{"oechr/s", "/s", "ICMP Echo reply messages sent"},
+			{"itm/s", "/s", "ICMP Timestamp request messages received"},
+			{"itmr/s", "/s", "ICMP Timestamp reply messages received"},
+			{"otm/s", "/s", "ICMP Timestamp request messages sent"},
+			{"otmr/s", "/s", "ICMP Timestamp reply messages sent"},
+			{"iadrmk/s", "/s", "ICMP Address Mask request messages received"},
+			{"iadrmkr/s", "/s", "ICMP Address Mask reply messages received"},
+			{"oadrmk/s", "/s", "ICMP Address Mask request messages sent"},
+			{"oadrmkr/s", "/s", "ICMP Address Mask reply messages sent"},
+		},
+	},
+	SECTION_NETWORK_EICMP: {
+		Name:       "ICMPv4 errors",
+		Signatures: [][]string{{"ierr/s", "oerr/s"}},
+		Columns: []sectionColumn{
+			{"ierr/s", "/s", "ICMP messages received with errors"},
+			{"oerr/s", "/s", "ICMP messages not sent because of problems within ICMP"},
+			{"idstunr/s", "/s", "ICMP Destination Unreachable messages received"},
+			{"odstunr/s", "/s", "ICMP Destination Unreachable messages sent"},
+			{"itmex/s", "/s", "ICMP Time Exceeded messages received"},
+			{"otmex/s", "/s", "ICMP Time Exceeded messages sent"},
+			{"iparmpb/s", "/s", "ICMP Parameter Problem messages received"},
+			{"oparmpb/s", "/s", "ICMP Parameter Problem messages sent"},
+			{"isrcq/s", "/s", "ICMP Source Quench messages received"},
+			{"osrcq/s", "/s", "ICMP Source Quench messages sent"},
+			{"iredir/s", "/s", "ICMP Redirect messages received"},
+			{"oredir/s", "/s", "ICMP Redirect messages sent"},
+		},
+	},
+	SECTION_NETWORK_TCP: {
+		Name:       "TCPv4 traffic",
+		Signatures: [][]string{{"active/s", "passive/s"}},
+		Columns: []sectionColumn{
+			{"active/s", "/s", "connections opened actively, CLOSED to SYN-SENT"},
+			{"passive/s", "/s", "connections opened passively, LISTEN to SYN-RCVD"},
+			{"iseg/s", "/s", "segments received"},
+			{"oseg/s", "/s", "segments sent, excluding retransmissions"},
+		},
+	},
+	SECTION_NETWORK_ETCP: {
+		Name:       "TCPv4 errors",
+		Signatures: [][]string{{"atmptf/s", "estres/s"}},
+		Columns: []sectionColumn{
+			{"atmptf/s", "/s", "failed connection attempts"},
+			{"estres/s", "/s", "connections reset from ESTABLISHED or CLOSE-WAIT"},
+			{"retrans/s", "/s", "segments retransmitted"},
+			{"isegerr/s", "/s", "segments received in error"},
+			{"orsts/s", "/s", "segments sent with the RST flag"},
+		},
+	},
+	SECTION_NETWORK_UDP: {
+		Name:       "UDPv4 traffic",
+		Signatures: [][]string{{"idgm/s", "odgm/s"}},
+		Columns: []sectionColumn{
+			{"idgm/s", "/s", "UDP datagrams delivered to UDP users"},
+			{"odgm/s", "/s", "UDP datagrams sent"},
+			{"noport/s", "/s", "UDP datagrams received with no application at the destination port"},
+			{"idgmerr/s", "/s", "UDP datagrams not delivered for other reasons"},
+		},
+	},
+	SECTION_NETWORK_SOCK6: {
+		Name:       "IPv6 sockets",
+		Signatures: [][]string{{"tcp6sck", "udp6sck"}},
+		Columns: []sectionColumn{
+			{"tcp6sck", "", "TCPv6 sockets in use"},
+			{"udp6sck", "", "UDPv6 sockets in use"},
+			{"raw6sck", "", "RAWv6 sockets in use"},
+			{"ip6-frag", "", "IPv6 fragments in use"},
+		},
+	},
+	SECTION_NETWORK_IP6: {
+		Name:       "IPv6 traffic",
+		Signatures: [][]string{{"irec6/s", "fwddgm6/s"}},
+		Columns: []sectionColumn{
+			{"irec6/s", "/s", "input datagrams received from interfaces"},
+			{"fwddgm6/s", "/s", "output datagrams forwarded"},
+			{"idel6/s", "/s", "datagrams delivered to IPv6 user-protocols"},
+			{"orq6/s", "/s", "IPv6 datagrams supplied for transmission by local user-protocols"},
+			{"asmrq6/s", "/s", "fragments received needing reassembly"},
+			{"asmok6/s", "/s", "IPv6 datagrams reassembled"},
+			{"imcpck6/s", "/s", "multicast packets received"},
+			{"omcpck6/s", "/s", "multicast packets sent"},
+			{"fragok6/s", "/s", "IPv6 datagrams fragmented"},
+			{"fragcr6/s", "/s", "IPv6 datagram fragments generated"},
+		},
+	},
+	SECTION_NETWORK_EIP6: {
+		Name:       "IPv6 errors",
+		Signatures: [][]string{{"ihdrer6/s", "iadrer6/s"}},
+		Columns: []sectionColumn{
+			{"ihdrer6/s", "/s", "input datagrams discarded for errors in their IPv6 headers"},
+			{"iadrer6/s", "/s", "input datagrams discarded for an invalid destination address"},
+			{"iukwnp6/s", "/s", "input datagrams discarded for an unknown or unsupported protocol"},
+			{"i2big6/s", "/s", "input datagrams too big to be forwarded"},
+			{"idisc6/s", "/s", "input datagrams discarded for lack of buffer space"},
+			{"odisc6/s", "/s", "output datagrams discarded for lack of buffer space"},
+			{"inort6/s", "/s", "input datagrams discarded because no route could be found"},
+			{"onort6/s", "/s", "output datagrams discarded because no route could be found"},
+			{"asmf6/s", "/s", "IPv6 reassembly failures"},
+			{"fragf6/s", "/s", "datagrams discarded because they could not be fragmented"},
+			{"itrpck6/s", "/s", "input datagrams discarded for being truncated"},
+		},
+	},
+	SECTION_NETWORK_ICMP6: {
+		Name:       "ICMPv6 traffic",
+		Signatures: [][]string{{"imsg6/s", "omsg6/s"}},
+		Columns: []sectionColumn{
+			{"imsg6/s", "/s", "ICMP messages received"},
+			{"omsg6/s", "/s", "ICMP messages sent"},
+			{"iech6/s", "/s", "ICMP Echo messages received"},
+			{"iechr6/s", "/s", "ICMP Echo Reply messages received"},
+			{"oechr6/s", "/s", "ICMP Echo Reply messages sent"},
+			{"igmbq6/s", "/s", "ICMPv6 Group Membership Query messages received"},
+			{"igmbr6/s", "/s", "ICMPv6 Group Membership Response messages received"},
+			{"ogmbr6/s", "/s", "ICMPv6 Group Membership Response messages sent"},
+			{"igmbrd6/s", "/s", "ICMPv6 Group Membership Reduction messages received"},
+			{"ogmbrd6/s", "/s", "ICMPv6 Group Membership Reduction messages sent"},
+			{"irtsol6/s", "/s", "ICMP Router Solicit messages received"},
+			{"ortsol6/s", "/s", "ICMP Router Solicitation messages sent"},
+			{"irtad6/s", "/s", "ICMP Router Advertisement messages received"},
+			{"inbsol6/s", "/s", "ICMP Neighbor Solicit messages received"},
+			{"onbsol6/s", "/s", "ICMP Neighbor Solicitation messages sent"},
+			{"inbad6/s", "/s", "ICMP Neighbor Advertisement messages received"},
+			{"onbad6/s", "/s", "ICMP Neighbor Advertisement messages sent"},
+		},
+	},
+	SECTION_NETWORK_EICMP6: {
+		Name:       "ICMPv6 errors",
+		Signatures: [][]string{{"ierr6/s", "idtunr6/s"}},
+		Columns: []sectionColumn{
+			{"ierr6/s", "/s", "ICMP messages received with errors"},
+			{"idtunr6/s", "/s", "ICMP Destination Unreachable messages received"},
+			{"odtunr6/s", "/s", "ICMP Destination Unreachable messages sent"},
+			{"itmex6/s", "/s", "ICMP Time Exceeded messages received"},
+			{"otmex6/s", "/s", "ICMP Time Exceeded messages sent"},
+			{"iprmpb6/s", "/s", "ICMP Parameter Problem messages received"},
+			{"oprmpb6/s", "/s", "ICMP Parameter Problem messages sent"},
+			{"iredir6/s", "/s", "ICMP Redirect messages received"},
+			{"oredir6/s", "/s", "ICMP Redirect messages sent"},
+			{"ipck2b6/s", "/s", "ICMP Packet Too Big messages received"},
+			{"opck2b6/s", "/s", "ICMP Packet Too Big messages sent"},
+		},
+	},
+	SECTION_NETWORK_UDP6: {
+		Name:       "UDPv6 traffic",
+		Signatures: [][]string{{"idgm6/s", "odgm6/s"}},
+		Columns: []sectionColumn{
+			{"idgm6/s", "/s", "UDP datagrams delivered to UDP users"},
+			{"odgm6/s", "/s", "UDP datagrams sent"},
+			{"noport6/s", "/s", "UDP datagrams received with no application at the destination port"},
+			{"idgmer6/s", "/s", "UDP datagrams not delivered for other reasons"},
+		},
+	},
+	SECTION_NETWORK_FC: {
+		Name:           "Fibre Channel traffic",
+		Signatures:     [][]string{{"FCHOST", "fch_rxf/s"}},
+		InstanceColumn: "FCHOST",
+		Columns: []sectionColumn{
+			{"FCHOST", "", "Fibre Channel host bus adapter"},
+			{"fch_rxf/s", "/s", "frames received"},
+			{"fch_txf/s", "/s", "frames transmitted"},
+			{"fch_rxw/s", "words/s", "received, in 4-byte words"},
+			{"fch_txw/s", "words/s", "transmitted, in 4-byte words"},
+		},
+	},
+	SECTION_PWR_CPUFREQ: {
+		Name:           "CPU frequency",
+		Signatures:     [][]string{{"CPU", "MHz"}},
+		InstanceColumn: "CPU",
+		Columns: []sectionColumn{
+			{"CPU", "", "processor number, all for the average of every processor"},
+			{"MHz", "MHz", "instantaneous CPU clock frequency"},
+		},
+	},
+	SECTION_PWR_FAN: {
+		Name: "Fan speed",
+		// sadf -d puts the device right after the fan number
+		Signatures:      [][]string{{"FAN", "rpm"}, {"FAN", "DEVICE", "rpm"}},
+		InstanceColumn:  "FAN",
+		InstanceDetails: []string{"DEVICE"},
+		TextColumns:     []string{"DEVICE"},
+		Columns: []sectionColumn{
+			{"FAN", "", "fan number"},
+			{"rpm", "rpm", "fan speed"},
+			{"drpm", "rpm", "fan speed above its low limit"},
+			{"DEVICE", "", "sensor device name"},
+		},
+	},
+	SECTION_PWR_IN: {
+		Name:            "Voltage inputs",
+		Signatures:      [][]string{{"IN", "inV"}, {"IN", "DEVICE", "inV"}},
+		InstanceColumn:  "IN",
+		InstanceDetails: []string{"DEVICE"},
+		TextColumns:     []string{"DEVICE"},
+		Columns: []sectionColumn{
+			{"IN", "", "voltage input number"},
+			{"inV", "V", "voltage input"},
+			{"%in", "%", "relative input value, 100% being the high limit and 0% the low one"},
+			{"DEVICE", "", "sensor device name"},
+		},
+	},
+	SECTION_PWR_TEMP: {
+		Name:            "Device temperature",
+		Signatures:      [][]string{{"TEMP", "degC"}, {"TEMP", "DEVICE", "degC"}},
+		InstanceColumn:  "TEMP",
+		InstanceDetails: []string{"DEVICE"},
+		TextColumns:     []string{"DEVICE"},
+		Columns: []sectionColumn{
+			{"TEMP", "", "temperature sensor number"},
+			{"degC", "degC", "temperature"},
+			{"%temp", "%", "relative temperature, 100% being the high limit"},
+			{"DEVICE", "", "sensor device name"},
+		},
+	},
+	SECTION_PWR_USB: {
+		Name:            "USB devices",
+		Signatures:      [][]string{{"BUS", "idvendor", "idprod"}},
+		InstanceColumn:  "BUS",
+		InstanceDetails: []string{"idvendor", "idprod", "product"},
+		TextColumns:     []string{"idvendor", "idprod", "manufact", "product"},
+		Columns: []sectionColumn{
+			{"BUS", "", "root hub number of the USB device"},
+			{"idvendor", "", "vendor ID"},
+			{"idprod", "", "product ID"},
+			{"maxpower", "mA", "maximum power consumption of the device"},
+			{"manufact", "", "manufacturer name"},
+			{"product", "", "product name"},
+		},
+	},
+	SECTION_INTR: {
+		Name:           "Interrupts",
+		Signatures:     [][]string{{"INTR", "intr/s"}},
+		InstanceColumn: "INTR",
+		Columns: []sectionColumn{
+			{"INTR", "", "interrupt number, sum for the total of every interrupt"},
+			{"intr/s", "/s", "interrupts received"},
+		},
+	},
+	SECTION_FILESYSTEM: {
+		Name: "Filesystem util",
+		// sar puts the filesystem last, sadf -d first
+		Signatures:      [][]string{{"MBfsfree", "MBfsused"}, {"FILESYSTEM", "MBfsfree"}, {"MOUNTPOINT", "MBfsfree"}},
+		InstanceColumn:  "FILESYSTEM",
+		InstanceAliases: []string{"MOUNTPOINT"},
+		TextColumns:     []string{"FILESYSTEM", "MOUNTPOINT"},
+		Columns: []sectionColumn{
+			{"MBfsfree", "MB", "free space"},
+			{"MBfsused", "MB", "used space"},
+			{"%fsused", "%", "used space"},
+			{"%ufsused", "%", "used space as seen by unprivileged users"},
+			{"Ifree", "", "free file nodes"},
+			{"Iused", "", "used file nodes"},
+			{"%Iused", "%", "used file nodes"},
+			{"FILESYSTEM", "", "filesystem"},
+			{"MOUNTPOINT", "", "mount point of the filesystem"},
+		},
+	},
+	SECTION_PSI_CPU: {
+		Name:       "CPU pressure",
+		Signatures: [][]string{{"%scpu-10", "%scpu-60"}},
+		Columns: []sectionColumn{
+			{"%scpu-10", "%", "time some runnable tasks were delayed for lack of CPU, last 10 seconds"},
+			{"%scpu-60", "%", "time some runnable tasks were delayed for lack of CPU, last 60 seconds"},
+			{"%scpu-300", "%", "time some runnable tasks were delayed for lack of CPU, last 300 seconds"},
+			{"%scpu", "%", "time some runnable tasks were delayed for lack of CPU, last interval"},
+		},
+	},
+	SECTION_PSI_IO: {
+		Name:       "IO pressure",
+		Signatures: [][]string{{"%sio-10", "%sio-60"}},
+		Columns: []sectionColumn{
+			{"%sio-10", "%", "time some tasks were stalled waiting for I/O, last 10 seconds"},
+			{"%sio-60", "%", "time some tasks were stalled waiting for I/O, last 60 seconds"},
+			{"%sio-300", "%", "time some tasks were stalled waiting for I/O, last 300 seconds"},
+			{"%sio", "%", "time some tasks were stalled waiting for I/O, last interval"},
+			{"%fio-10", "%", "time all non-idle tasks were stalled waiting for I/O, last 10 seconds"},
+			{"%fio-60", "%", "time all non-idle tasks were stalled waiting for I/O, last 60 seconds"},
+			{"%fio-300", "%", "time all non-idle tasks were stalled waiting for I/O, last 300 seconds"},
+			{"%fio", "%", "time all non-idle tasks were stalled waiting for I/O, last interval"},
+		},
+	},
+	SECTION_PSI_MEM: {
+		Name:       "Memory pressure",
+		Signatures: [][]string{{"%smem-10", "%smem-60"}},
+		Columns: []sectionColumn{
+			{"%smem-10", "%", "time some tasks were stalled waiting for memory, last 10 seconds"},
+			{"%smem-60", "%", "time some tasks were stalled waiting for memory, last 60 seconds"},
+			{"%smem-300", "%", "time some tasks were stalled waiting for memory, last 300 seconds"},
+			{"%smem", "%", "time some tasks were stalled waiting for memory, last interval"},
+			{"%fmem-10", "%", "time all non-idle tasks were stalled waiting for memory, last 10 seconds"},
+			{"%fmem-60", "%", "time all non-idle tasks were stalled waiting for memory, last 60 seconds"},
+			{"%fmem-300", "%", "time all non-idle tasks were stalled waiting for memory, last 300 seconds"},
+			{"%fmem", "%", "time all non-idle tasks were stalled waiting for memory, last interval"},
+		},
+	},
+}
+
+func init() {
+	if len(sectionRegistry) != SECTION_END {
+		panic("sectionRegistry is out of sync with the SECTION_* constants")
+	}
+}
+
+// matchSectionHeader finds the section of a header line by its leading columns,
+// the longest signature wins and user definitions win over built-in ones
+func matchSectionHeader(segs []string) (int, bool) {
+	sectionId, matched := 0, 0
+	for id := len(sectionRegistry) - 1; id >= 0; id-- {
+		for _, sig := range sectionRegistry[id].Signatures {
+			if len(sig) > matched && (hasPrefix(segs, sig) || sectionRegistry[id].hasPrettyPrefix(segs, sig)) {
+				sectionId, matched = id, len(sig)
+			}
+		}
+	}
+	return sectionId, matched > 0
+}
+
+// hasPrettyPrefix matches a signature led by the instance column against a header of "sar -h",
+// whose --pretty moves the device or interface name to the end of the line
+func (d *sectionDef) hasPrettyPrefix(segs, sig []string) bool {
+	if len(sig) < 2 || len(segs) < len(sig) || segs[len(segs)-1] != sig[0] {
+		return false
+	}
+	if sig[0] != d.InstanceColumn && !contains(d.InstanceAliases, sig[0]) {
+		return false
+	}
+	return hasPrefix(segs, sig[1:])
+}
+
+func hasPrefix(segs, prefix []string) bool {
+	if len(segs) < len(prefix) {
+		return false
+	}
+	for idx := range prefix {
+		if segs[idx] != prefix[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func sectionName(sectionId int) string {
+	if sectionId < 0 || sectionId >= len(sectionRegistry) {
+		return ""
+	}
+	return sectionRegistry[sectionId].Name
+}
+
+func getSectionIdByName(name string) (int, bool) {
+	for id, def := range sectionRegistry {
+		if def.Name == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// instanceColumns are the columns which name the instance (cpu, device, interface...) of a data line
+func instanceColumns() []string {
+	var cols []string
+	for _, def := range sectionRegistry {
+		if NO_INSTANCE != def.InstanceColumn && !contains(cols, def.InstanceColumn) {
+			cols = append(cols, def.InstanceColumn)
+		}
+	}
+	return cols
+}
+
+// instanceOf names the instance a data line belongs to, NO_INSTANCE for single-instance sections
+func (d *sectionDef) instanceOf(headerSegs, segs []string) string {
+	if NO_INSTANCE == d.InstanceColumn {
+		return NO_INSTANCE
+	}
+	value := func(col string) (string, bool) {
+		for idx := range headerSegs {
+			if col == headerSegs[idx] && idx < len(segs) {
+				return segs[idx], true
+			}
+		}
+		return "", false
+	}
+
+	instance, found := value(d.InstanceColumn)
+	for idx := 0; !found && idx < len(d.InstanceAliases); idx++ {
+		instance, found = value(d.InstanceAliases[idx])
+	}
+	var details []string
+	for _, col := range d.InstanceDetails {
+		if val, _ := value(col); "" != val {
+			details = append(details, val)
+		}
+	}
+	if len(details) > 0 {
+		instance = fmt.Sprintf("%s (%s)", instance, strings.Join(details, " "))
+	}
+	return instance
+}
+
+// isChartable tells if a column holds numbers
+func (d *sectionDef) isChartable(col string) bool {
+	return col != d.InstanceColumn && !contains(d.InstanceAliases, col) && !contains(d.TextColumns, col)
+}
+
+// trailingTextColumns counts the text columns at the end of a header
+func (d *sectionDef) trailingTextColumns(headerSegs []string) int {
+	n := 0
+	for n < len(headerSegs) && contains(d.TextColumns, headerSegs[len(headerSegs)-1-n]) {
+		n++
+	}
+	return n
+}
+
+// getSectionColumn returns the unit and description of a column, if the section documents it
+func getSectionColumn(sectionId int, name string) (sectionColumn, bool) {
+	if sectionId < 0 || sectionId >= len(sectionRegistry) {
+		return sectionColumn{}, false
+	}
+	for _, col := range sectionRegistry[sectionId].Columns {
+		if col.Name == name {
+			return col, true
+		}
+	}
+	return sectionColumn{}, false
+}
+
+func (d *sectionDef) validate() error {
+	if "" == d.Name {
+		return fmt.Errorf("section has no name")
+	}
+	if len(d.Signatures) == 0 {
+		return fmt.Errorf("section \"%s\" has no signatures", d.Name)
+	}
+	for _, sig := range d.Signatures {
+		if len(sig) == 0 {
+			return fmt.Errorf("section \"%s\" has an empty signature", d.Name)
+		}
+	}
+	return nil
+}
+
+// addSectionDef registers a user section, replacing the section of the same name if there is one
+func addSectionDef(def *sectionDef) error {
+	if err := def.validate(); nil != err {
+		return err
+	}
+	if id, found := getSectionIdByName(def.Name); found {
+		sectionRegistry[id] = def
+		return nil
+	}
+	sectionRegistry = append(sectionRegistry, def)
+	return nil
+}
+
+// LoadSectionConfig reads user section definitions from a JSON file holding a list of sections, e.g.
+//
+//	[{"name": "Memory util", "signatures": [["kbmemfree", "kbavail"], ["kbmemfree", "kbmemused"]],
+//	  "columns": [{"name": "kbavail", "unit": "kB", "description": "memory available without swapping"}]}]
+func LoadSectionConfig(path string) error {
+	f, err := os.Open(path)
+	if nil != err {
+		return err
+	}
+	defer f.Close()
+
+	var defs []*sectionDef
+	if err := json.NewDecoder(f).Decode(&defs); nil != err {
+		return fmt.Errorf("invalid section config %s: %v", path, err)
+	}
+	for _, def := range defs {
+		if err := addSectionDef(def); nil != err {
+			return fmt.Errorf("invalid section config %s: %v", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package sarsar
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchSectionHeader(t *testing.T) {
+	sectionId, found := matchSectionHeader(strings.Fields("kbmemfree kbavail kbmemused %memused"))
+	assert.True(t, found)
+	assert.Equal(t, SECTION_MEM_UTIL, sectionId)
+
+	sectionId, found = matchSectionHeader(strings.Fields("kbmemfree kbmemused %memused"))
+	assert.True(t, found)
+	assert.Equal(t, SECTION_MEM_UTIL, sectionId)
+
+	sectionId, found = matchSectionHeader(strings.Fields("CPU total/s dropd/s"))
+	assert.True(t, found)
+	assert.Equal(t, SECTION_NETWORK_SOFT, sectionId)
+
+	// sar -h prints the device or interface last
+	sectionId, found = matchSectionHeader(strings.Fields("tps rkB/s wkB/s dkB/s areq-sz aqu-sz await %util DEV"))
+	assert.True(t, found)
+	assert.Equal(t, SECTION_BLOCK_DEV, sectionId)
+
+	sectionId, found = matchSectionHeader(strings.Fields("rxerr/s txerr/s coll/s IFACE"))
+	assert.True(t, found)
+	assert.Equal(t, SECTION_NETWORK_EDEV, sectionId)
+
+	_, found = matchSectionHeader(strings.Fields("tps rkB/s wkB/s"))
+	assert.False(t, found)
+
+	_, found = matchSectionHeader(strings.Fields("kbmemfree"))
+	assert.False(t, found)
+}
+
+const sarCustom = `Linux 4.15.0-20-generic (db01) 	03/14/2018 	_x86_64_	(2 CPU)
+
+10:00:01 AM      GPU   %gpu  %gmem
+10:10:01 AM        0  50.00  10.00
+10:10:01 AM        1  70.00  20.00
+`
+
+const sectionConfig = `[
+	{"name": "GPU util", "signatures": [["GPU", "%gpu"]], "instance_column": "GPU",
+	 "columns": [{"name": "%gpu", "unit": "%", "description": "GPU busy time"}]},
+	{"name": "Task Creation & Switch", "signatures": [["proc/s", "cswch/s"], ["proc/s", "ctxsw/s"]]}
+]`
+
+func TestLoadSectionConfig(t *testing.T) {
+	builtin := sectionRegistry
+	defer func() {
+		sectionRegistry = builtin
+	}()
+	sectionRegistry = append([]*sectionDef{}, builtin...)
+
+	_, err := parseSarReader(strings.NewReader(sarCustom), true)
+	assert.Error(t, err)
+
+	tmp, err := ioutil.TempFile("", "sections")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	tmp.WriteString(sectionConfig)
+	tmp.Close()
+
+	if !assert.NoError(t, LoadSectionConfig(tmp.Name())) {
+		return
+	}
+	assert.Equal(t, SECTION_END+1, len(sectionRegistry))
+
+	f, err := parseSarReader(strings.NewReader(sarCustom), true)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, values, err := f.getDataSeriesByName("GPU util", "1", "%gpu")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{70}, values)
+
+	sectionId, _ := getSectionIdByName("GPU util")
+	info, found := getSectionColumn(sectionId, "%gpu")
+	assert.True(t, found)
+	assert.Equal(t, "%", info.Unit)
+
+	// replaced, not added
+	sectionId, found = matchSectionHeader(strings.Fields("proc/s ctxsw/s"))
+	assert.True(t, found)
+	assert.Equal(t, SECTION_TASK_CREATION_AND_SYS_SWITCH, sectionId)
+}
+
+func TestLoadSectionConfigInvalid(t *testing.T) {
+	tmp, err := ioutil.TempFile("", "sections")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	tmp.WriteString(`[{"name": "no signature"}]`)
+	tmp.Close()
+
+	assert.Error(t, LoadSectionConfig(tmp.Name()))
+	assert.Equal(t, SECTION_END, len(sectionRegistry))
+}
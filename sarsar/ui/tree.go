@@ -14,13 +14,16 @@ const PREFIX_LEVEL_INDENT = "  "
 const PREFIX_COLLAPSE = "+ "
 const PREFIX_LEAF = ". "
 const PREFIX_EXPAND = "- "
+const PREFIX_MARKED = "* "
 
 type TreeNode struct {
 	Name          string
 	Nodes         []*TreeNode
 	bindKeyOnce   sync.Once
 	enterCallback TreeNodeEnterCallbackFn
+	markCallback  TreeNodeMarkCallbackFn
 	isExpand      bool
+	isMarked      bool
 	HideName      bool
 }
 
@@ -43,6 +46,7 @@ func (n *TreeNode) bindKey(g *gocui.Gui, v *gocui.View) error {
 		g.SetKeybinding(v.Name(), gocui.KeyArrowDown, gocui.ModNone, n.onCursorDown)
 		g.SetKeybinding(v.Name(), gocui.KeyArrowUp, gocui.ModNone, n.onCursorUp)
 		g.SetKeybinding(v.Name(), gocui.KeyEnter, gocui.ModNone, n.onEnter)
+		g.SetKeybinding(v.Name(), gocui.KeySpace, gocui.ModNone, n.onSpace)
 	})
 	return nil
 }
@@ -95,6 +99,46 @@ func (n *TreeNode) getLevel(line string) int {
 	return (len(line) - len(lineTrimSpace)) / 2
 }
 
+// keysAt returns the names from the node at line cy up to the root, leaf first
+func (n *TreeNode) keysAt(v *gocui.View, cy int) []string {
+	var segs []string
+	i := cy
+	lastLevel := math.MaxInt32
+	for i >= 0 {
+		seg, _ := v.Line(i)
+		level := n.getLevel(seg)
+		if level < lastLevel {
+			lastLevel = level
+			segs = append(segs, n.getRawLabel(seg))
+		}
+		if 0 == level {
+			break
+		}
+		i--
+	}
+
+	if n.HideName {
+		segs = append(segs, n.Name)
+	}
+	return segs
+}
+
+// findPath walks the keys from the root down, nil if a node is gone
+func (n *TreeNode) findPath(segs []string) *TreeNode {
+	var curr *TreeNode
+	for i := len(segs) - 1; i >= 0; i-- {
+		if nil == curr {
+			curr = n
+		} else {
+			curr = curr.findNode(segs[i])
+		}
+		if nil == curr {
+			return nil
+		}
+	}
+	return curr
+}
+
 func (n *TreeNode) onEnter(g *gocui.Gui, v *gocui.View) error {
 	var line string
 	var err error
@@ -104,41 +148,12 @@ func (n *TreeNode) onEnter(g *gocui.Gui, v *gocui.View) error {
 		line = ""
 	}
 
-	var segs []string
-	{
-		i := cy
-		lastLevel := math.MaxInt32
-		for i >= 0 {
-			seg, _ := v.Line(i)
-			level := n.getLevel(seg)
-			if level < lastLevel {
-				lastLevel = level
-				segs = append(segs, n.getRawLabel(seg))
-			}
-			if 0 == level {
-				break
-			}
-			i--
-		}
-
-		if n.HideName {
-			segs = append(segs, n.Name)
-		}
-	}
+	segs := n.keysAt(v, cy)
 
 	lineTrimSpace := strings.TrimLeft(line, PREFIX_LEVEL_INDENT)
 	if strings.HasPrefix(lineTrimSpace, PREFIX_COLLAPSE) || strings.HasPrefix(lineTrimSpace, PREFIX_EXPAND) {
-		var curr *TreeNode
-		for i := len(segs) - 1; i >= 0; i-- {
-			if nil == curr {
-				curr = n
-			} else {
-				curr = curr.findNode(segs[i])
-			}
-
-			if nil != curr && 0 == i {
-				curr.Switch()
-			}
+		if curr := n.findPath(segs); nil != curr {
+			curr.Switch()
 		}
 		return n.Render(g, v)
 	}
@@ -150,6 +165,24 @@ func (n *TreeNode) onEnter(g *gocui.Gui, v *gocui.View) error {
 	return nil
 }
 
+// onSpace marks or unmarks the leaf under the cursor, if the mark callback accepts it
+func (n *TreeNode) onSpace(g *gocui.Gui, v *gocui.View) error {
+	_, cy := v.Cursor()
+	segs := n.keysAt(v, cy)
+	curr := n.findPath(segs)
+	if nil == curr || len(curr.Nodes) > 0 {
+		return nil
+	}
+
+	if nil != n.markCallback {
+		if err := n.markCallback(g, v, segs, !curr.isMarked); nil != err {
+			return nil
+		}
+	}
+	curr.isMarked = !curr.isMarked
+	return n.Render(g, v)
+}
+
 func (n *TreeNode) getRawLabel(l string) string {
 	for {
 		trimmed := l
@@ -157,6 +190,7 @@ func (n *TreeNode) getRawLabel(l string) string {
 		trimmed = strings.TrimPrefix(trimmed, PREFIX_COLLAPSE)
 		trimmed = strings.TrimPrefix(trimmed, PREFIX_LEAF)
 		trimmed = strings.TrimPrefix(trimmed, PREFIX_EXPAND)
+		trimmed = strings.TrimPrefix(trimmed, PREFIX_MARKED)
 		if l == trimmed {
 			return l
 		}
@@ -170,6 +204,13 @@ func (n *TreeNode) SetEnterCallback(callback TreeNodeEnterCallbackFn) {
 	n.enterCallback = callback
 }
 
+// TreeNodeMarkCallbackFn is called before a leaf gets marked or unmarked, an error keeps it as it is
+type TreeNodeMarkCallbackFn func(g *gocui.Gui, v *gocui.View, keys []string, marked bool) error
+
+func (n *TreeNode) SetMarkCallback(callback TreeNodeMarkCallbackFn) {
+	n.markCallback = callback
+}
+
 var regexpLineHeader = regexp.MustCompile("(?m)^([^$])")
 
 func (n *TreeNode) innerRender() string {
@@ -190,7 +231,9 @@ func (n *TreeNode) innerRender() string {
 			}
 		}
 	} else {
-		if !n.HideName {
+		if !n.HideName && n.isMarked {
+			fmt.Fprintln(buf, PREFIX_MARKED+n.Name)
+		} else if !n.HideName {
 			fmt.Fprintln(buf, PREFIX_LEAF+n.Name)
 		}
 	}
@@ -0,0 +1,119 @@
+package sarsar
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// MIN_WINDOW_SAMPLES is as far as zooming in goes
+	MIN_WINDOW_SAMPLES = 4
+	ZOOM_FACTOR        = 2
+	// PAN_FRACTION of the window is scrolled by a pan
+	PAN_FRACTION = 4
+)
+
+// chartWindow is the visible part of the chart, by sample times so that it stays put
+// when other columns are charted. The zero window shows everything.
+type chartWindow struct {
+	from time.Time
+	to   time.Time
+}
+
+// makeChartWindow shows the samples [lo, hi) of the times
+func makeChartWindow(times []time.Time, lo, hi int) chartWindow {
+	if lo <= 0 && hi >= len(times) {
+		return chartWindow{}
+	}
+	return chartWindow{from: times[lo], to: times[hi-1]}
+}
+
+func (w chartWindow) isZoomed() bool {
+	return !w.from.IsZero()
+}
+
+func (w chartWindow) contains(ts time.Time) bool {
+	return !w.isZoomed() || !ts.Before(w.from) && !ts.After(w.to)
+}
+
+// indexes returns the visible samples [lo, hi) of the times, all of them if none is visible
+func (w chartWindow) indexes(times []time.Time) (int, int) {
+	if !w.isZoomed() {
+		return 0, len(times)
+	}
+	lo := sort.Search(len(times), func(i int) bool {
+		return !times[i].Before(w.from)
+	})
+	hi := sort.Search(len(times), func(i int) bool {
+		return times[i].After(w.to)
+	})
+	if lo >= hi {
+		return 0, len(times)
+	}
+	return lo, hi
+}
+
+// zoom narrows (zoomIn) or widens the window by ZOOM_FACTOR around the sample at center
+func (w chartWindow) zoom(times []time.Time, center int, zoomIn bool) chartWindow {
+	lo, hi := w.indexes(times)
+	width := (hi - lo) * ZOOM_FACTOR
+	if zoomIn {
+		width = (hi - lo) / ZOOM_FACTOR
+	}
+	if width < MIN_WINDOW_SAMPLES {
+		width = MIN_WINDOW_SAMPLES
+	}
+	if width >= len(times) {
+		return chartWindow{}
+	}
+	// keep the center where it is on the screen
+	lo, hi = clampWindow(center-(center-lo)*width/(hi-lo), width, len(times))
+	return makeChartWindow(times, lo, hi)
+}
+
+// pan scrolls the window by a PAN_FRACTION of its width, backwards if delta is negative
+func (w chartWindow) pan(times []time.Time, delta int) chartWindow {
+	if !w.isZoomed() {
+		return w
+	}
+	lo, hi := w.indexes(times)
+	step := (hi - lo) / PAN_FRACTION
+	if step < 1 {
+		step = 1
+	}
+	lo, hi = clampWindow(lo+delta*step, hi-lo, len(times))
+	return makeChartWindow(times, lo, hi)
+}
+
+// follow pans the window so that the sample at idx is visible, in the middle unless at an end
+func (w chartWindow) follow(times []time.Time, idx int) chartWindow {
+	lo, hi := w.indexes(times)
+	if !w.isZoomed() || idx >= lo && idx < hi {
+		return w
+	}
+	lo, hi = clampWindow(idx-(hi-lo)/2, hi-lo, len(times))
+	return makeChartWindow(times, lo, hi)
+}
+
+// clampWindow keeps a window of width samples starting at lo within count samples
+func clampWindow(lo, width, count int) (int, int) {
+	if lo+width > count {
+		lo = count - width
+	}
+	if lo < 0 {
+		lo = 0
+	}
+	return lo, lo + width
+}
+
+func (w chartWindow) String() string {
+	if !w.isZoomed() {
+		return ""
+	}
+	layout := "15:04:05"
+	if w.from.YearDay() != w.to.YearDay() || w.from.Year() != w.to.Year() {
+		layout = "Jan 02 15:04:05"
+	}
+	return fmt.Sprintf("%s-%s", w.from.Format(layout), w.to.Format(layout))
+}
@@ -0,0 +1,91 @@
+package sarsar
+
+import (
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeTimes(count int) []time.Time {
+	var times []time.Time
+	for idx := 0; idx < count; idx++ {
+		times = append(times, time.Date(2018, 3, 14, 0, idx, 0, 0, time.UTC))
+	}
+	return times
+}
+
+func TestChartWindowZoom(t *testing.T) {
+	times := makeTimes(100)
+	var w chartWindow
+	lo, hi := w.indexes(times)
+	assert.Equal(t, []int{0, 100}, []int{lo, hi})
+
+	w = w.zoom(times, 50, true)
+	lo, hi = w.indexes(times)
+	assert.Equal(t, []int{25, 75}, []int{lo, hi})
+	assert.Equal(t, "00:25:00-01:14:00", w.String())
+	assert.True(t, w.contains(times[25]))
+	assert.False(t, w.contains(times[75]))
+
+	// the sample under the center stays in place
+	w = w.zoom(times, 35, true)
+	lo, hi = w.indexes(times)
+	assert.Equal(t, []int{30, 55}, []int{lo, hi})
+
+	for i := 0; i < 10; i++ {
+		w = w.zoom(times, 40, true)
+	}
+	lo, hi = w.indexes(times)
+	assert.Equal(t, MIN_WINDOW_SAMPLES, hi-lo)
+
+	for i := 0; i < 10; i++ {
+		w = w.zoom(times, 40, false)
+	}
+	assert.False(t, w.isZoomed())
+}
+
+func TestChartWindowPan(t *testing.T) {
+	times := makeTimes(100)
+	var w chartWindow
+	assert.Equal(t, w, w.pan(times, 1))
+
+	w = makeChartWindow(times, 10, 50)
+	w = w.pan(times, -1)
+	lo, hi := w.indexes(times)
+	assert.Equal(t, []int{0, 40}, []int{lo, hi})
+
+	w = w.pan(times, 1).pan(times, 5)
+	lo, hi = w.indexes(times)
+	assert.Equal(t, []int{60, 100}, []int{lo, hi})
+
+	// a window out of the samples of other columns shows them all
+	lo, hi = w.indexes(makeTimes(30))
+	assert.Equal(t, []int{0, 30}, []int{lo, hi})
+}
+
+func TestChartDataSlice(t *testing.T) {
+	data := &chartData{
+		times:  makeTimes(6),
+		labels: []string{"a", "b", "c", "d", "e", "f"},
+		series: []chartSeries{{values: []float64{0, 1, 2, 3, 4, 5}}},
+		breaks: []sarBreak{{index: 2}, {index: 3, restart: true}, {index: 5}},
+	}
+	sliced := data.slice(2, 5)
+	assert.Equal(t, []string{"c", "d", "e"}, sliced.labels)
+	assert.Equal(t, []float64{2, 3, 4}, sliced.series[0].values)
+	assert.Equal(t, []sarBreak{{index: 1, restart: true}}, sliced.breaks)
+	assert.Equal(t, []float64{0, 1, 2, 3, 4, 5}, data.series[0].values)
+}
+
+func TestChartWindowFollow(t *testing.T) {
+	times := makeTimes(100)
+	var w chartWindow
+	assert.Equal(t, w, w.follow(times, 90))
+
+	w = makeChartWindow(times, 10, 30)
+	assert.Equal(t, w, w.follow(times, 20))
+	lo, hi := w.follow(times, 50).indexes(times)
+	assert.Equal(t, []int{40, 60}, []int{lo, hi})
+	lo, hi = w.follow(times, 95).indexes(times)
+	assert.Equal(t, []int{80, 100}, []int{lo, hi})
+}